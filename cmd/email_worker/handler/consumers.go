@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -10,8 +11,11 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/metrics"
+	"github.com/jsndz/signalbus/pkg/fallback"
+	"github.com/jsndz/signalbus/pkg/gate"
 	"github.com/jsndz/signalbus/pkg/gomailer"
 	"github.com/jsndz/signalbus/pkg/kafka"
+	"github.com/jsndz/signalbus/pkg/lanes"
 	"github.com/jsndz/signalbus/pkg/models"
 	"github.com/jsndz/signalbus/pkg/repositories"
 	"github.com/jsndz/signalbus/pkg/templates"
@@ -27,16 +31,18 @@ import (
 const maxRetries = 3
 
 func HandleMail(broker string, 
-    ctx context.Context, mailService gomailer.Mailer, 
+    ctx context.Context, mailers map[string]gomailer.Mailer, 
     logger *zap.Logger,tmplRepo *repositories.TemplateRepository,
-    notificationRepo *repositories.NotificationRepository,producer *kafka.Producer,
+    notificationRepo *repositories.NotificationRepository,
+    suppressionRepo *repositories.SuppressionRepository,deliveryGate *gate.Gate,producer *kafka.Producer,
     provider string,tracer trace.Tracer,
 ) {
 	topic := "notification.email"
-	c := kafka.NewConsumerFromEnv(topic,"email")
+	// every priority lane, high first by weight
+	c := lanes.NewReader(ctx, "email", "email", lanes.DefaultWeights)
 	defer c.Close()
 
-	logger.Info("Starting Kafka consumer", zap.String("topic", topic), zap.String("broker", broker))
+	logger.Info("Starting Kafka consumer", zap.String("topic", topic), zap.Strings("lanes", lanes.All), zap.String("broker", broker))
 
 	for {
 		select {
@@ -45,7 +51,7 @@ func HandleMail(broker string,
 			return
 
 		default:
-			m, err := c.ReadFromKafka(ctx)
+			m, err := c.Read(ctx)
 			if err != nil {
 				logger.Error("Error reading Kafka message", zap.String("topic", topic), zap.Error(err))
 				continue
@@ -70,6 +76,37 @@ func HandleMail(broker string,
                     )
                     return
                 }    
+                decision, err := deliveryGate.Check(msg, time.Now())
+                if err != nil {
+                    logger.Warn("Delivery gate check failed, sending anyway",
+                        zap.String("notification_id", msg.NotificationId.String()),
+                        zap.Error(err),
+                    )
+                }
+                if decision.Action == gate.Defer {
+                    err := deliveryGate.Hold(emailCtx, msg, m.Key, decision)
+                    if errors.Is(err, gate.ErrFinal) {
+                        // a redelivery of a message that was handled already
+                        logger.Info("Notification is final, skipping email",
+                            zap.String("notification_id", msg.NotificationId.String()),
+                        )
+                        return
+                    }
+                    if err != nil {
+                        logger.Error("Failed to defer email",
+                            zap.String("notification_id", msg.NotificationId.String()),
+                            zap.Error(err),
+                        )
+                        return
+                    }
+                    logger.Info("Email deferred",
+                        zap.String("notification_id", msg.NotificationId.String()),
+                        zap.String("reason", decision.Reason),
+                        zap.Time("until", decision.Until),
+                    )
+                    return
+                }
+
                  _, tmplspan := tracer.Start(emailCtx, "template extraction")
 
                 var htmlContent, textContent string            
@@ -84,7 +121,7 @@ func HandleMail(broker string,
                  }   else {
                     content ,err := templates.Render(
                         msg.InTemplateData,
-                        
+                        msg.TenantID,
                         "email",
                         msg.GetTemplateData.EventType,
                         msg.GetTemplateData.Locale,
@@ -105,6 +142,7 @@ func HandleMail(broker string,
                 }
                 logger.Info("Kafka message received",
                     zap.String("topic", topic),
+                    zap.String("lane", m.Lane),
                     zap.ByteString("key", m.Key),
                     zap.Int64("offset", m.Offset),
                 )
@@ -126,9 +164,52 @@ func HandleMail(broker string,
                 }
                 mail := gomailer.NewEmail(user.From,user.To,
                     gomailer.WithHTML(htmlContent),gomailer.WithText(textContent),
-                    gomailer.WithSubject(user.Subject))
+                    gomailer.WithSubject(user.Subject),
+                    gomailer.WithCustomArg("notification_id", msg.NotificationId.String()),
+                    gomailer.WithCustomArg("tenant_id", msg.TenantID.String()))
+
+                // a policy rule may have picked another configured provider
+                mailService, sendProvider := mailers[provider], provider
+                if m, ok := mailers[msg.Provider]; ok {
+                    mailService, sendProvider = m, msg.Provider
+                } else if msg.Provider != "" {
+                    logger.Warn("Provider override is not configured, using default",
+                        zap.String("provider", msg.Provider),
+                        zap.String("notification_id", msg.NotificationId.String()),
+                    )
+                }
+
+                // addresses may have bounced or complained since the
+                // notification was accepted
+                to, hit, err := suppressionRepo.Filter(msg.TenantID, "email", mail.To, time.Now())
+                if err != nil {
+                    logger.Warn("Suppression check failed, sending anyway",
+                        zap.String("notification_id", msg.NotificationId.String()),
+                        zap.Error(err),
+                    )
+                } else if len(to) == 0 && hit != nil {
+                    reason := "recipient suppressed: " + hit.Reason
+                    notificationRepo.MarkSuppressed(msg.TenantID, msg.NotificationId, reason)
+                    notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+                        TenantID:       msg.TenantID,
+                        NotificationID: msg.NotificationId,
+                        Channel:        "email",
+                        Provider:       sendProvider,
+                        Status:         "suppressed",
+                        Error:          reason,
+                        Try:            1,
+                    })
+                    metrics.NotificationsAttemptedTotal.WithLabelValues("email", "suppressed", sendProvider).Inc()
+                    logger.Info("Recipient suppressed, skipping send",
+                        zap.String("notification_id", msg.NotificationId.String()),
+                        zap.String("reason", hit.Reason),
+                    )
+                    return
+                } else {
+                    mail.To = to
+                }
 
-                SendEmailWithRetry(emailCtx,logger,mailService,mail,producer,msg.NotificationId,notificationRepo,provider,tracer);
+                SendEmailWithRetry(emailCtx,logger,mailService,mail,msg,producer,msg.TenantID,msg.NotificationId,notificationRepo,sendProvider,tracer);
             }()
 		}
 	}
@@ -139,7 +220,9 @@ func SendEmailWithRetry(
     logger *zap.Logger,
     mailService gomailer.Mailer,
     mail gomailer.Email,
+    msg types.KafkaStreamData,
     producer *kafka.Producer,
+    tenantID uuid.UUID,
     notificationID uuid.UUID,
     notificationRepo *repositories.NotificationRepository,
     provider string,
@@ -150,23 +233,54 @@ func SendEmailWithRetry(
     _, sendSpan := tracer.Start(ctx, "send-email")
 	defer sendSpan.End()
     for attempt := 1; attempt <= maxRetries; attempt++ {
+        // the notification may have been recalled since it was queued or
+        // while this worker was backing off
+        if cancelled, err := notificationRepo.Cancelled(tenantID, notificationID); err != nil {
+            logger.Warn("Couldn't check whether the notification was cancelled",
+                zap.String("notification_id", notificationID.String()),
+                zap.Error(err),
+            )
+        } else if cancelled {
+            notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+                TenantID:       tenantID,
+                NotificationID: notificationID,
+                Channel:        "email",
+                Provider:       provider,
+                Status:         "cancelled",
+                Try:            attempt,
+            })
+            metrics.NotificationsAttemptedTotal.WithLabelValues("email", "cancelled", provider).Inc()
+            sendSpan.AddEvent("notification cancelled")
+            logger.Info("Notification cancelled, skipping send",
+                zap.String("notification_id", notificationID.String()),
+                zap.Int("attempt", attempt),
+            )
+            return nil
+        }
+
         start := time.Now()
         apiTimer := prometheus.NewTimer(metrics.ExternalAPIDuration.WithLabelValues(provider, "email"))
-        _,err := mailService.Send(mail)
+        resp, err := mailService.Send(mail)
         apiTimer.ObserveDuration()
         latency := time.Since(start).Milliseconds()
 
         if err == nil {
             metrics.NotificationsAttemptedTotal.WithLabelValues("email", "success", provider).Inc()
 
-            notificationRepo.UpdateStatus(notificationID, "delivered")
+            notificationRepo.UpdateStatus(tenantID, notificationID, "delivered")
+            var providerMessageID string
+            if resp != nil {
+                providerMessageID = resp.ProviderID
+            }
             notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+                TenantID:       tenantID,
                 NotificationID: notificationID,
                 Channel:        "email",
                 Provider:       provider,
                 Status:         "delivered",
                 Try:            attempt,
                 LatencyMs:      latency,
+                ProviderMessageID: providerMessageID,
             })
             metrics.ExternalAPISuccessTotal.WithLabelValues(provider, "email_worker").Inc()
 
@@ -180,11 +294,13 @@ func SendEmailWithRetry(
         jitter := time.Duration(rand.Intn(500)) * time.Millisecond
         waitTime := backoffDelay + jitter
    		metrics.NotificationRetriesTotal.WithLabelValues("provider_error","email").Inc()
+        notificationRepo.MarkRetrying(tenantID, notificationID)
         notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+            TenantID:       tenantID,
             NotificationID: notificationID,
             Channel:        "email",
             Provider:       provider,
-            Status:         "retrying",
+            Status:         "failed",
             Error:          err.Error(),
             Try:            attempt,
             LatencyMs:      latency,
@@ -200,7 +316,28 @@ func SendEmailWithRetry(
     }
 
     metrics.ExternalAPIFailureTotal.WithLabelValues(provider, "email_worker").Inc()
-    notificationRepo.UpdateStatus(notificationID, "failed")
+    // a cancel that landed during the last backoff wins over the failure
+    if failed, err := notificationRepo.MarkFailed(tenantID, notificationID); err != nil {
+        logger.Warn("Couldn't mark the notification failed",
+            zap.String("notification_id", notificationID.String()),
+            zap.Error(err),
+        )
+    } else if !failed {
+        notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+            TenantID:       tenantID,
+            NotificationID: notificationID,
+            Channel:        "email",
+            Provider:       provider,
+            Status:         "cancelled",
+            Try:            maxRetries,
+        })
+        metrics.NotificationsAttemptedTotal.WithLabelValues("email", "cancelled", provider).Inc()
+        sendSpan.AddEvent("notification cancelled")
+        logger.Info("Notification cancelled, skipping DLQ and fallback",
+            zap.String("notification_id", notificationID.String()),
+        )
+        return nil
+    }
 
     mailBytes, mErr := json.Marshal(mail)
     if mErr != nil {
@@ -231,13 +368,25 @@ func SendEmailWithRetry(
         dlqSpan.SetStatus(codes.Ok, "dlq published")
     }
 
-	metrics.NotificationDLQTotal.WithLabelValues("provider_error","email")
-    notificationRepo.CreateAttempt(&models.DeliveryAttempt{
-        NotificationID: notificationID,
-        Channel:        "email",
-        Provider:       provider,
-        Status:         "dlq",
-        Message:        mailBytes,
-    })
+	metrics.NotificationDLQTotal.WithLabelValues("provider_error","email").Inc()
+    // redrive republishes the message the worker consumed
+    if attempt, err := repositories.NewDLQAttempt(msg, "email", provider); err != nil {
+        logger.Error("Failed to marshal message for DLQ attempt",
+            zap.String("notification_id", notificationID.String()),
+            zap.Error(err),
+        )
+    } else if err := notificationRepo.CreateAttempt(attempt); err != nil {
+        logger.Error("Failed to record DLQ attempt",
+            zap.String("notification_id", notificationID.String()),
+            zap.Error(err),
+        )
+    }
+
+    if err := fallback.Publish(ctx, producer, tenantID, notificationID, "email", "provider_error"); err != nil {
+        logger.Error("Failed to publish fallback event",
+            zap.String("notification_id", notificationID.String()),
+            zap.Error(err),
+        )
+    }
     return fmt.Errorf("permanent email failure after %d retries", maxRetries)
 }
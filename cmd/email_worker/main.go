@@ -14,6 +14,7 @@ import (
 	"github.com/jsndz/signalbus/middlewares"
 	"github.com/jsndz/signalbus/pkg/config"
 	"github.com/jsndz/signalbus/pkg/database"
+	"github.com/jsndz/signalbus/pkg/gate"
 	"github.com/jsndz/signalbus/pkg/kafka"
 	"github.com/jsndz/signalbus/pkg/repositories"
 	"github.com/jsndz/signalbus/pkg/utils"
@@ -47,6 +48,8 @@ func main() {
 		panic("failed to initialize Database: " + err.Error())
 	}
 	notification_repo := repositories.NewNotificationRepository(notification_db)
+	suppression_repo := repositories.NewSuppressionRepository(notification_db)
+	delivery_gate := gate.New(notification_db)
 
 	logr.Info("Starting email worker service")
 
@@ -73,13 +76,13 @@ func main() {
 	if err!=nil {
 		logr.Fatal(err.Error(), zap.Error(err))
 	}
-	Mailer,err := config.BuildMailer(cfg)
+	mailers,err := config.BuildMailers(cfg)
 	if err!=nil {
 		logr.Fatal(err.Error(), zap.Error(err))
 	}
 	logr.Info("Mail service initialized")
 
-	go handler.HandleMail(broker, ctx, Mailer, logr, tmpl_repo,notification_repo,producer,cfg.Email.Provider,tracer)
+	go handler.HandleMail(broker, ctx, mailers, logr, tmpl_repo,notification_repo,suppression_repo,delivery_gate,producer,cfg.Email.Provider,tracer)
 	wrappedMux := middlewares.MetricsMiddleware(mux)
 	go handleShutdown(producer, logr)
 
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/eventschema"
+	"github.com/jsndz/signalbus/pkg/types"
+	"go.uber.org/zap"
+)
+
+// maxBatchItems caps POST /api/notify/batch so one request cannot hold a
+// connection for an unbounded amount of work.
+const maxBatchItems = 100
+
+type batchNotifyRequest struct {
+	Items []json.RawMessage `json:"items" binding:"required"`
+}
+
+type batchItemResult struct {
+	Index           int         `json:"index"`
+	IdempotencyKey  string      `json:"idempotency_key,omitempty"`
+	Status          int         `json:"status"`
+	NotificationIDs []uuid.UUID `json:"notification_ids,omitempty"`
+	SuppressedIDs   []uuid.UUID `json:"suppressed_ids,omitempty"`
+	CollapsedIDs    []uuid.UUID `json:"collapsed_ids,omitempty"`
+	BufferedIDs     []uuid.UUID `json:"buffered_ids,omitempty"`
+	SendAt          *time.Time  `json:"send_at,omitempty"`
+	DeferredUntil   *time.Time  `json:"deferred_until,omitempty"`
+	Replayed        bool        `json:"replayed,omitempty"`
+	Error           string      `json:"error,omitempty"`
+	// Violations lists why the payload does not match its event schema.
+	Violations []eventschema.Violation `json:"violations,omitempty"`
+}
+
+func (r *batchItemResult) fill(resp notifyResponse) {
+	r.NotificationIDs = resp.NotificationIDs
+	r.SuppressedIDs = resp.SuppressedIDs
+	r.CollapsedIDs = resp.CollapsedIDs
+	r.BufferedIDs = resp.BufferedIDs
+	r.SendAt = resp.SendAt
+	r.DeferredUntil = resp.DeferredUntil
+}
+
+// notifyResponse is the body Notify returns, stored under an idempotency key
+// and shared with batch items so either endpoint can replay the other's.
+type notifyResponse struct {
+	Message         string      `json:"message"`
+	NotificationIDs []uuid.UUID `json:"notification_ids"`
+	SuppressedIDs   []uuid.UUID `json:"suppressed_ids,omitempty"`
+	CollapsedIDs    []uuid.UUID `json:"collapsed_ids,omitempty"`
+	BufferedIDs     []uuid.UUID `json:"buffered_ids,omitempty"`
+	SendAt          *time.Time  `json:"send_at,omitempty"`
+	DeferredUntil   *time.Time  `json:"deferred_until,omitempty"`
+}
+
+// BatchNotify accepts up to maxBatchItems notify requests. Each item is
+// validated, routed through its policy and stored independently, so one bad
+// item does not fail the rest; the 207 body carries a result per item.
+func (h *NotificationHandler) BatchNotify(store *middlewares.IdempotencyStore, log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req batchNotifyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: " + err.Error()})
+			return
+		}
+		if len(req.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "items cannot be empty"})
+			return
+		}
+		if len(req.Items) > maxBatchItems {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a batch can hold at most %d items", maxBatchItems)})
+			return
+		}
+
+		// Items hash as if they had been sent to POST /api/notify/ on their
+		// own, so a key first used there replays here and vice versa.
+		notifyRoute := strings.TrimSuffix(c.FullPath(), "batch")
+
+		results := make([]batchItemResult, len(req.Items))
+		accepted := 0
+		for i, raw := range req.Items {
+			results[i] = h.notifyItem(c, store, log, notifyRoute, i, raw)
+			if results[i].Status < 300 {
+				accepted++
+			}
+		}
+
+		c.JSON(http.StatusMultiStatus, gin.H{
+			"accepted": accepted,
+			"failed":   len(results) - accepted,
+			"results":  results,
+		})
+	}
+}
+
+func (h *NotificationHandler) notifyItem(
+	c *gin.Context,
+	store *middlewares.IdempotencyStore,
+	log *zap.Logger,
+	notifyRoute string,
+	index int,
+	raw json.RawMessage,
+) batchItemResult {
+	result := batchItemResult{Index: index}
+	fail := func(status int, err error) batchItemResult {
+		result.Status = status
+		result.Error = err.Error()
+		return result
+	}
+
+	var item types.BatchNotifyItem
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return fail(http.StatusBadRequest, errors.New("invalid JSON: "+err.Error()))
+	}
+	if err := binding.Validator.ValidateStruct(&item.NotifyRequest); err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+
+	key := strings.TrimSpace(item.IdempotencyKey)
+	result.IdempotencyKey = key
+	if err := middlewares.ValidateIdempotencyKey(key); err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+
+	tenantID := middlewares.GetTenantID(c)
+	var hash string
+	if key != "" {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return fail(http.StatusBadRequest, err)
+		}
+		delete(fields, "idempotency_key")
+		body, err := json.Marshal(fields)
+		if err != nil {
+			return fail(http.StatusBadRequest, err)
+		}
+		hash = middlewares.RequestHash(http.MethodPost, notifyRoute, body)
+
+		if replayed, err := replayItem(c, store, tenantID, key, hash, &result); replayed || err != nil {
+			if err != nil {
+				return fail(http.StatusUnprocessableEntity, err)
+			}
+			return result
+		}
+
+		release, err := store.Reserve(c, tenantID, key)
+		if errors.Is(err, middlewares.ErrIdempotencyInProgress) {
+			return fail(http.StatusConflict, err)
+		}
+		if err != nil {
+			log.Error("failed to reserve idempotency key", zap.Int("index", index), zap.Error(err))
+			return fail(http.StatusServiceUnavailable, errors.New("could not reserve idempotency key"))
+		}
+		defer release()
+
+		if replayed, err := replayItem(c, store, tenantID, key, hash, &result); replayed || err != nil {
+			if err != nil {
+				return fail(http.StatusUnprocessableEntity, err)
+			}
+			return result
+		}
+	}
+
+	plan, err := h.planNotify(c.Request.Context(), tenantID, &item.NotifyRequest, key)
+	if err != nil {
+		var invalid *eventschema.ValidationError
+		if errors.As(err, &invalid) {
+			result.Violations = invalid.Violations
+		}
+		return fail(http.StatusBadRequest, err)
+	}
+	if plan.CollapseErr != nil {
+		log.Warn("collapse window unavailable, sending without collapsing",
+			zap.Int("index", index), zap.Error(plan.CollapseErr))
+	}
+	if err := h.usageService.CheckQuota(tenantID, services.AcceptedCounts(plan.Items), time.Now()); err != nil {
+		h.abandonPlan(c.Request.Context(), log, plan)
+		var exceeded *services.QuotaError
+		if errors.As(err, &exceeded) {
+			return fail(exceeded.Status, err)
+		}
+		log.Error("failed to check quota", zap.Int("index", index), zap.Error(err))
+		return fail(http.StatusInternalServerError, errors.New("failed to check quota"))
+	}
+	if err := h.notificationService.Enqueue(c.Request.Context(), plan.Items); err != nil {
+		h.abandonPlan(c.Request.Context(), log, plan)
+		log.Error("failed to enqueue batch item", zap.Int("index", index), zap.Error(err))
+		return fail(http.StatusInternalServerError, errors.New("failed to create notification"))
+	}
+
+	resp := plan.response()
+	result.Status = http.StatusAccepted
+	result.fill(resp)
+
+	if key != "" {
+		body, _ := json.Marshal(resp)
+		if err := store.Save(c, tenantID, key, middlewares.StoredResponse{
+			RequestHash: hash,
+			StatusCode:  http.StatusAccepted,
+			Body:        string(body),
+		}); err != nil {
+			log.Error("failed to store idempotency record", zap.Int("index", index), zap.Error(err))
+		}
+	}
+	return result
+}
+
+// replayItem fills result from a response stored under key. It returns
+// ErrIdempotencyConflict when the key belongs to a different request.
+func replayItem(
+	c *gin.Context,
+	store *middlewares.IdempotencyStore,
+	tenantID uuid.UUID,
+	key, hash string,
+	result *batchItemResult,
+) (bool, error) {
+	stored, err := store.Lookup(c, tenantID, key, hash)
+	if errors.Is(err, middlewares.ErrIdempotencyConflict) {
+		return false, err
+	}
+	if err != nil || stored == nil {
+		return false, nil
+	}
+	var prev notifyResponse
+	if err := json.Unmarshal([]byte(stored.Body), &prev); err != nil {
+		return false, nil
+	}
+	result.Status = stored.StatusCode
+	result.fill(prev)
+	result.Replayed = true
+	return true, nil
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type ContactHandler struct {
+	service *services.ContactService
+}
+
+func NewContactHandler(db *gorm.DB) *ContactHandler {
+	return &ContactHandler{service: services.NewContactService(db)}
+}
+
+// SetContact creates or replaces the contact for user_ref. Every field is
+// overwritten, so send the full contact.
+func (h *ContactHandler) SetContact(c *gin.Context) {
+	var req struct {
+		Emails     []string          `json:"emails"`
+		Phones     []string          `json:"phones"`
+		Locale     string            `json:"locale"`
+		Timezone   string            `json:"timezone"`
+		Attributes models.Attributes `json:"attributes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contact := &models.Contact{
+		TenantID:   middlewares.GetTenantID(c),
+		UserRef:    c.Param("user_ref"),
+		Emails:     req.Emails,
+		Phones:     req.Phones,
+		Locale:     req.Locale,
+		Timezone:   req.Timezone,
+		Attributes: req.Attributes,
+	}
+	if err := h.service.SetContact(contact); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+func (h *ContactHandler) GetContact(c *gin.Context) {
+	contact, err := h.service.GetContact(middlewares.GetTenantID(c), c.Param("user_ref"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "contact not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+func (h *ContactHandler) DeleteContact(c *gin.Context) {
+	if err := h.service.DeleteContact(middlewares.GetTenantID(c), c.Param("user_ref")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/eventschema"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type EventSchemaHandler struct {
+	service *services.EventSchemaService
+}
+
+func NewEventSchemaHandler(db *gorm.DB) *EventSchemaHandler {
+	return &EventSchemaHandler{service: services.NewEventSchemaService(db)}
+}
+
+func (h *EventSchemaHandler) CreateSchema(c *gin.Context) {
+	var req struct {
+		EventType string          `json:"event_type" binding:"required"`
+		Schema    json.RawMessage `json:"schema" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schema := &models.EventSchema{
+		TenantID:  middlewares.GetTenantID(c),
+		EventType: req.EventType,
+		Schema:    models.JSONDocument(req.Schema),
+	}
+	if err := h.service.CreateSchema(schema); err != nil {
+		if errors.Is(err, services.ErrSchemaExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, schema)
+}
+
+func (h *EventSchemaHandler) ListSchemas(c *gin.Context) {
+	schemas, err := h.service.ListSchemas(middlewares.GetTenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schemas)
+}
+
+func (h *EventSchemaHandler) GetSchema(c *gin.Context) {
+	schema, err := h.service.GetSchema(middlewares.GetTenantID(c), c.Param("event_type"))
+	if err != nil {
+		schemaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schema)
+}
+
+func (h *EventSchemaHandler) UpdateSchema(c *gin.Context) {
+	var req struct {
+		Schema json.RawMessage `json:"schema" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, eventType := middlewares.GetTenantID(c), c.Param("event_type")
+	if err := h.service.UpdateSchema(tenantID, eventType, models.JSONDocument(req.Schema)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			schemaError(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schema, err := h.service.GetSchema(tenantID, eventType)
+	if err != nil {
+		schemaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schema)
+}
+
+func (h *EventSchemaHandler) DeleteSchema(c *gin.Context) {
+	if err := h.service.DeleteSchema(middlewares.GetTenantID(c), c.Param("event_type")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ValidatePayload checks a payload against the stored schema without sending
+// anything. A mismatch is still a 200; the body says what is wrong.
+func (h *EventSchemaHandler) ValidatePayload(c *gin.Context) {
+	var req struct {
+		Data         map[string]interface{} `json:"data"`
+		TemplateData map[string]interface{} `json:"template_data"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.DryRun(middlewares.GetTenantID(c), c.Param("event_type"), req.Data, req.TemplateData)
+	var invalid *eventschema.ValidationError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"valid": true, "violations": []eventschema.Violation{}})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusOK, gin.H{"valid": false, "violations": invalid.Violations})
+	default:
+		schemaError(c, err)
+	}
+}
+
+func schemaError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no schema for this event type"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -2,22 +2,27 @@ package handler
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/collapse"
+	"github.com/jsndz/signalbus/pkg/eventschema"
 	"github.com/jsndz/signalbus/pkg/gosms"
-	"github.com/jsndz/signalbus/pkg/kafka"
+	"github.com/jsndz/signalbus/pkg/lanes"
 	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/rules"
+	"github.com/jsndz/signalbus/pkg/repositories"
 	"github.com/jsndz/signalbus/pkg/types"
-	"go.opentelemetry.io/otel"
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -26,17 +31,37 @@ import (
 type NotificationHandler struct {
 	notificationService *services.NotificationService
 	policyService *services.PolicyService
+	attemptService *services.DeliveryAttemptService
+	preferenceService *services.PreferenceService
+	quietHoursService *services.QuietHoursService
+	contactService *services.ContactService
+	schemaService *services.EventSchemaService
+	usageService *services.UsageService
+	suppressionService *services.SuppressionService
+	collapseStore *collapse.Store
 }
 
-func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
-	return &NotificationHandler{notificationService: services.NewNotificationService(db),policyService: services.NewPolicyService(db)}
+func NewNotificationHandler(db *gorm.DB, redisClient *redis.Client) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: services.NewNotificationService(db),
+		policyService: services.NewPolicyService(db),
+		attemptService: services.NewDeliveryAttemptService(db),
+		preferenceService: services.NewPreferenceService(db),
+		quietHoursService: services.NewQuietHoursService(db),
+		contactService: services.NewContactService(db),
+		schemaService: services.NewEventSchemaService(db),
+		usageService: services.NewUsageService(db),
+		suppressionService: services.NewSuppressionService(db),
+		collapseStore: collapse.NewStore(redisClient),
+	}
 }
 
-func (h *NotificationHandler) Notify(p *kafka.Producer,db *gorm.DB, log *zap.Logger, tracer trace.Tracer) gin.HandlerFunc {
+func (h *NotificationHandler) Notify(log *zap.Logger, tracer trace.Tracer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tracer_context, span := tracer.Start(c.Request.Context(), "handle-sending")
 		defer span.End()
-		idem_key := c.GetHeader("X-Idempotency-Key")
+		idem_key := strings.TrimSpace(c.GetHeader(middlewares.IdempotencyHeader))
+		tenantID := middlewares.GetTenantID(c)
 		
 		log.Info("Incoming HTTP request",
 			zap.String("endpoint", "/notify"),
@@ -52,169 +77,268 @@ func (h *NotificationHandler) Notify(p *kafka.Producer,db *gorm.DB, log *zap.Log
 			return
 		}
 
-		
+		plan, err := h.planNotify(tracer_context, tenantID, &req, idem_key)
+		if err != nil {
+			log.Warn("rejected notify request", zap.String("event_type", req.EventType), zap.Error(err))
+			rejectPayload(c, err)
+			return
+		}
+		if plan.CollapseErr != nil {
+			log.Warn("collapse window unavailable, sending without collapsing",
+				zap.String("collapse_key", req.CollapseKey), zap.Error(plan.CollapseErr))
+		}
+		if err := h.usageService.CheckQuota(tenantID, services.AcceptedCounts(plan.Items), time.Now()); err != nil {
+			h.abandonPlan(tracer_context, log, plan)
+			if !rejectQuota(c, err) {
+				log.Error("failed to check quota", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check quota"})
+			}
+			return
+		}
 
-		var record models.IdempotencyKey
-		err := db.Where("key = ? ", idem_key).First(&record).Error
-		if err == nil {
-			c.Data(record.StatusCode, "application/json", []byte(record.Response))
+		_, dbSpan := tracer.Start(tracer_context, "enqueue-notifications")
+		if err := h.notificationService.Enqueue(tracer_context, plan.Items); err != nil {
+			dbSpan.RecordError(err)
+			dbSpan.SetStatus(codes.Error, err.Error())
+			dbSpan.End()
+			h.abandonPlan(tracer_context, log, plan)
+			log.Error("failed to enqueue notifications", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create notification"})
 			return
 		}
+		dbSpan.SetStatus(codes.Ok, "notifications enqueued")
+		dbSpan.End()
 
-		reqBytes, _ := json.Marshal(struct {
-			EventType    string                 `json:"event_type"`
-			Data         map[string]interface{} `json:"data"`
-			TemplateData map[string]interface{} `json:"template_data,omitempty"`
-		}{
-			EventType:    req.EventType,
-			Data:         req.UserData,
-			TemplateData: req.TemplateData,
-		})
+		c.JSON(http.StatusAccepted, plan.response())
+	}
+}
+
+// notifyPlan is what a notify request expands to before it is stored.
+type notifyPlan struct {
+	Items []services.OutboundNotification
+	// SendAt is the requested delivery time, nil for immediate delivery.
+	SendAt *time.Time
+	// DeferredUntil is set when quiet hours pushed delivery back.
+	DeferredUntil *time.Time
+	// CollapseErr is set when the collapse window could not be checked; the
+	// notifications then go out uncollapsed.
+	CollapseErr error
+	// claims are the collapse windows the plan's notifications hold.
+	claims []collapseClaim
+}
+
+type collapseClaim struct {
+	key      string
+	id       uuid.UUID
+	previous uuid.UUID
+}
+
+func (p *notifyPlan) response() notifyResponse {
+	return notifyResponse{
+		Message:         "Notification accepted",
+		NotificationIDs: notificationIDs(p.Items),
+		SuppressedIDs:   idsWithStatus(p.Items, "suppressed"),
+		CollapsedIDs:    idsWithStatus(p.Items, "collapsed"),
+		BufferedIDs:     idsWithStatus(p.Items, "buffered"),
+		SendAt:          p.SendAt,
+		DeferredUntil:   p.DeferredUntil,
+	}
+}
+
+// planNotify validates req and expands it into one notification per channel
+// and recipient, taking the channels from the first policy rule that matches
+// the recipient or from the policy itself. A channel the user opted out of
+// yields a single suppressed notification instead, unless the policy is
+// transactional, and delivery that would land in the user's quiet hours is
+// deferred to the end of the window unless the policy is urgent. Email for a
+// digest policy is buffered for the next digest instead. Recipients
+// without a "to" in data are looked up in the contact directory, and those
+// on the suppression list are dropped; a notification left without any is
+// suppressed. Errors describe why the request was rejected; a payload that
+// does not match the event type's schema yields an
+// *eventschema.ValidationError. Requests with a collapse key are folded into
+// an open collapse window, see collapsePlan.
+func (h *NotificationHandler) planNotify(ctx context.Context, tenantID uuid.UUID, req *types.NotifyRequest, idemKey string) (*notifyPlan, error) {
+	now := time.Now()
+	sendAt, err := req.Schedule.Resolve(now)
+	if err != nil {
+		return nil, err
+	}
+	if err := lanes.Validate(req.Priority); err != nil {
+		return nil, err
+	}
+	if err := h.schemaService.Validate(tenantID, req.EventType, req.UserData, req.TemplateData); err != nil {
+		return nil, err
+	}
+
+	payloads, err := splitPayload(req.UserData, req.TemplateData)
+	if err != nil {
+		return nil, errors.New("invalid payload")
+	}
+	policy, err := h.policyService.GetPolicyByTopic(tenantID, req.EventType)
+	if err != nil {
+		return nil, fmt.Errorf("no policy for event type %q", req.EventType)
+	}
+	prefs, err := h.preferenceService.ListPreferences(tenantID, req.UserRef)
+	if err != nil {
+		return nil, fmt.Errorf("load preferences: %w", err)
+	}
+	contact, err := h.contactService.FindContact(tenantID, req.UserRef)
+	if err != nil {
+		return nil, fmt.Errorf("load contact: %w", err)
+	}
 
-		sum := sha256.Sum256(reqBytes)
-		reqHash := hex.EncodeToString(sum[:])
+	priority := req.Priority
+	if priority == "" && policy.Urgent {
+		priority = lanes.High
+	}
 
-		payloads, err := splitPayload(req.UserData, req.TemplateData)
+	plan := &notifyPlan{SendAt: sendAt}
+	deliverAt := sendAt
+	status := ""
+	if !policy.Urgent {
+		window, err := h.quietHoursService.EffectiveWindow(tenantID, req.UserRef, policy)
 		if err != nil {
-			log.Error("failed to split payload", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
-			return
+			return nil, fmt.Errorf("load quiet hours: %w", err)
 		}
-		policy,err := h.policyService.GetPolicyByTopic(req.EventType)
-		if err != nil {
-			log.Error("failed to get policy payload", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
-			return
+		at := now
+		if sendAt != nil {
+			at = *sendAt
 		}
-		locale := "en-US"
-		if v, ok := req.TemplateData["locale"]; ok {
-			if sLocale, ok := v.(string); ok && sLocale != "" {
-				locale = sLocale
-			}
+		if until, ok := window.DeferUntil(at); ok {
+			until = until.UTC()
+			deliverAt = &until
+			plan.DeferredUntil = &until
+			status = "deferred"
 		}
+	}
 
-		for _, channel := range policy.Channels {
-			topic := "notification." + channel
-			for _, pl := range payloads {
-				_, dbSpan := tracer.Start(tracer_context, "create-notification")
-				if channel == "sms" {
-					num, ok := pl.RecieverData["to"].(string)
-					if !ok || num == "" {
-						c.JSON(http.StatusBadRequest, gin.H{"error": "missing or invalid 'to' field for SMS"})
-						return
-					}
-					to, err := gosms.NormalizeSMS(num)
-					if err != nil {
-						c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-						return
-					}
-					pl.RecieverData["to"] = to
+	locale := "en-US"
+	if policy.Locale != "" {
+		locale = policy.Locale
+	}
+	if contact != nil && contact.Locale != "" {
+		locale = contact.Locale
+	}
+	if v, ok := req.TemplateData["locale"]; ok {
+		if sLocale, ok := v.(string); ok && sLocale != "" {
+			locale = sLocale
+		}
+	}
+
+	suppressed := make(map[string]bool)
+	for _, pl := range payloads {
+		route, err := rules.Select(policy, rules.Env{
+			"data":          req.UserData,
+			"template_data": req.TemplateData,
+			"recipient":     pl.RecieverData,
+			"event_type":    req.EventType,
+			"user_ref":      req.UserRef,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, channel := range route.Channels {
+			if !policy.Transactional && !prefs.Allows(req.EventType, channel) {
+				// one record per channel, however many recipients there are
+				if !suppressed[channel] {
+					suppressed[channel] = true
+					plan.Items = append(plan.Items, services.OutboundNotification{
+						Notification: models.Notification{
+							TenantID:     tenantID,
+							Topic:        req.EventType,
+							Channel:      channel,
+							UserRef:      req.UserRef,
+							Status:       "suppressed",
+							StatusReason: "user opted out of " + channel,
+							RuleOutcome:  route.String(),
+						},
+					})
 				}
-				notification_id, err := h.notificationService.CreateNotification(req.EventType, channel, req.UserRef)
+				continue
+			}
 
+			recieverData := copyMap(pl.RecieverData)
+			if !hasRecipient(recieverData) {
+				if err := resolveRecipient(recieverData, contact, channel, req.UserRef); err != nil {
+					return nil, err
+				}
+			}
+			if channel == "sms" {
+				num, ok := recieverData["to"].(string)
+				if !ok || num == "" {
+					return nil, errors.New("missing or invalid 'to' field for SMS")
+				}
+				to, err := gosms.NormalizeSMS(num)
 				if err != nil {
-					dbSpan.RecordError(err)
-					dbSpan.SetStatus(codes.Error, err.Error())
-					log.Error("failed to create notification", zap.Error(err))
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create notification"})
-					return
+					return nil, err
 				}
-				dbSpan.SetStatus(codes.Ok, "notification created")
-
-					
+				recieverData["to"] = to
+			}
 
-				msg := types.KafkaStreamData{
-					IdempotencyKey: idem_key,
-					RecieverData:   pl.RecieverData,
+			n := models.Notification{
+				TenantID:    tenantID,
+				Topic:       req.EventType,
+				Channel:     channel,
+				UserRef:     req.UserRef,
+				Priority:    priority,
+				Status:      status,
+				SendAt:      deliverAt,
+				RuleOutcome: route.String(),
+			}
+			if status == "deferred" {
+				n.StatusReason = "quiet hours"
+			}
+			var digest *models.DigestItem
+			if channel == "email" && !policy.Digest.IsZero() && !policy.Urgent && sendAt == nil {
+				n.Status = "buffered"
+				n.StatusReason = "held for the " + policy.Digest.Window + " digest"
+				n.SendAt = nil
+				digest = &models.DigestItem{
+					Locale:    locale,
+					Recipient: recieverData,
+					Data:      pl.InTemplateData,
+					DueAt:     policy.Digest.Closes(now),
+				}
+			}
+			plan.Items = append(plan.Items, services.OutboundNotification{
+				Notification: n,
+				Digest: digest,
+				Key: idemKey,
+				Message: types.KafkaStreamData{
+					IdempotencyKey: idemKey,
+					RecieverData:   recieverData,
 					InTemplateData: pl.InTemplateData,
 					GetTemplateData: &types.GetTemplateData{
 						EventType: req.EventType,
 						Locale:    locale,
 					},
-					NotificationId: notification_id,
-				}
-
-				msgBytes, err := json.Marshal(msg)
-				if err != nil {
-					log.Error("failed to marshal kafka message", zap.Error(err))
-					continue
-				}
-				ctx := c.Request.Context()
-				headers := make(map[string]string)
-				otel.GetTextMapPropagator().Inject(tracer_context, propagation.MapCarrier(headers))
-				_, kafkaSpan := tracer.Start(tracer_context, "publish-kafka")
-				if err := p.Publish(ctx, topic, []byte(idem_key), msgBytes); err != nil {
-					kafkaSpan.RecordError(err)
-					kafkaSpan.SetStatus(codes.Error, err.Error())
-					log.Error("failed to publish message", zap.String("topic", topic), zap.Error(err))
-				} else {
-					log.Debug("published message", zap.String("topic", topic), )
-					kafkaSpan.SetStatus(codes.Ok, "published")
-
-				}
-				dbSpan.End()
-					kafkaSpan.End()
-			}
-		}
-
-		resp := gin.H{"message": "Notification accepted"}
-		respBytes, _ := json.Marshal(resp)
-
-		if err := db.Create(&models.IdempotencyKey{
-			Key:         idem_key,
-			RequestHash: reqHash,
-			Response:    string(respBytes),
-			StatusCode:  http.StatusAccepted,
-		}).Error; err != nil {
-			log.Error("failed to create idempotency record", zap.Error(err))
+					Provider: route.ProviderFor(channel),
+				},
+			})
 		}
-		c.JSON(http.StatusAccepted, resp)
 	}
-}
-
-func PublishNotification(
-	ctx context.Context,
-	producer *kafka.Producer,
-	logger *zap.Logger,
-	channel string,
-	idempotencyKey string,
-	msg types.KafkaStreamData,
-) error {
-	topic := fmt.Sprintf("notification.%s", channel)
-	msg.IdempotencyKey = idempotencyKey
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
-		logger.Error("failed to marshal Kafka message", zap.Error(err))
-		return fmt.Errorf("marshal kafka message: %w", err)
+	if err := h.suppressionService.Apply(tenantID, plan.Items, now); err != nil {
+		return nil, fmt.Errorf("check suppressions: %w", err)
 	}
-
-	if err := producer.Publish(ctx, topic, []byte(idempotencyKey), msgBytes); err != nil {
-		logger.Error("failed to publish Kafka message",
-			zap.String("topic", topic),
-			zap.Error(err),
-		)
-		return fmt.Errorf("publish kafka message: %w", err)
+	if req.CollapseKey != "" {
+		rule := collapse.Resolve(models.CollapseRule{Window: req.CollapseWindow, Mode: req.CollapseMode}, policy.Collapse)
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("collapse: %w", err)
+		}
+		if rule.IsZero() {
+			return nil, errors.New("collapse_key needs a collapse_window on the request or its policy")
+		}
+		plan.CollapseErr = h.collapsePlan(ctx, tenantID, req, rule, plan, now)
 	}
-
-	logger.Info("Kafka message published successfully",
-		zap.String("topic", topic),
-		zap.String("notification_id", msg.NotificationId.String()),
-	)
-	return nil
+	return plan, nil
 }
-func (h *NotificationHandler) Publish(
-	p *kafka.Producer,
-	db *gorm.DB,
-	log *zap.Logger,
-) gin.HandlerFunc {
+
+func (h *NotificationHandler) Publish(log *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		idemKey := c.GetHeader("X-Idempotency-Key")
-	
-		var record models.IdempotencyKey
-		if err := db.Where("key = ?", idemKey).First(&record).Error; err == nil {
-			c.Data(record.StatusCode, "application/json", []byte(record.Response))
-			return
-		}
+		idemKey := strings.TrimSpace(c.GetHeader(middlewares.IdempotencyHeader))
+		tenantID := middlewares.GetTenantID(c)
 
 		var req types.PublishRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -222,6 +346,20 @@ func (h *NotificationHandler) Publish(
 			return
 		}
 
+		sendAt, err := req.Schedule.Resolve(time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := lanes.Validate(req.Priority); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := h.schemaService.Validate(tenantID, req.EventType, req.UserData, nil); err != nil {
+			rejectPayload(c, err)
+			return
+		}
+
 		payloads, err := splitPayload(req.UserData, nil)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
@@ -241,40 +379,53 @@ func (h *NotificationHandler) Publish(
 			}
 		}
 
+		items := make([]services.OutboundNotification, 0, len(payloads))
 		for _, pl := range payloads {
-			notificationID, err := h.notificationService.CreateNotification(
-				 req.EventType, req.Channel, req.UserRef,
-			)
-			if err != nil {
-				log.Error("failed to create notification", zap.Error(err))
-				continue
-			}
-
-			msg := types.KafkaStreamData{
-				RecieverData:   pl.RecieverData,
-				TextMessage:    req.TextMessage,
-				HTMLMessage:    req.HTMLMessage,
-				NotificationId: notificationID,
-			}
+			items = append(items, services.OutboundNotification{
+				Notification: models.Notification{
+					TenantID: tenantID,
+					Topic:    req.EventType,
+					Channel:  req.Channel,
+					UserRef:  req.UserRef,
+					Priority: req.Priority,
+					SendAt:   sendAt,
+				},
+				Key: idemKey,
+				Message: types.KafkaStreamData{
+					IdempotencyKey: idemKey,
+					RecieverData:   pl.RecieverData,
+					TextMessage:    req.TextMessage,
+					HTMLMessage:    req.HTMLMessage,
+				},
+			})
+		}
+		if err := h.suppressionService.Apply(tenantID, items, time.Now()); err != nil {
+			log.Error("failed to check suppressions", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check suppressions"})
+			return
+		}
 
-			if err := PublishNotification(c.Request.Context(), p, log, req.Channel, idemKey, msg); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to publish notification"})
-				return
+		if err := h.usageService.CheckQuota(tenantID, services.AcceptedCounts(items), time.Now()); err != nil {
+			if !rejectQuota(c, err) {
+				log.Error("failed to check quota", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check quota"})
 			}
+			return
 		}
 
-		resp := gin.H{"message": "Notification published"}
-		respBytes, _ := json.Marshal(resp)
-
-		if err :=db.Create(&models.IdempotencyKey{
-			Key:         idemKey,
-			Response:    string(respBytes),
-			StatusCode:  http.StatusAccepted,
-		});
-		err != nil {
-			log.Error("failed to create idempotency record", zap.Error(err.Error))
+		if err := h.notificationService.Enqueue(c.Request.Context(), items); err != nil {
+			log.Error("failed to enqueue notifications", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to publish notification"})
+			return
 		}
 
+		resp := gin.H{"message": "Notification published", "notification_ids": notificationIDs(items)}
+		if ids := idsWithStatus(items, "suppressed"); len(ids) > 0 {
+			resp["suppressed_ids"] = ids
+		}
+		if sendAt != nil {
+			resp["send_at"] = sendAt
+		}
 		c.JSON(http.StatusAccepted, resp)
 	}
 }
@@ -288,7 +439,55 @@ func (h *NotificationHandler) GetNotification(log *zap.Logger) gin.HandlerFunc {
 			return
 		}
 
-		notification, err := h.notificationService.GetNotification(id)
+		tenantID := middlewares.GetTenantID(c)
+		notification, err := h.notificationService.GetNotification(tenantID, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
+		if err != nil {
+			log.Error("failed to fetch notification",
+				zap.String("id", idStr),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch notification"})
+			return
+		}
+
+		result := services.NotificationResult{Notification: *notification}
+		if c.Query("expand") == "attempts" {
+			result.Attempts, err = h.attemptService.ListAttempts(tenantID, id)
+			if err != nil {
+				log.Error("failed to fetch delivery attempts",
+					zap.String("id", idStr),
+					zap.Error(err),
+				)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch delivery attempts"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ListAttempts returns the delivery timeline of one notification, oldest
+// attempt first.
+func (h *NotificationHandler) ListAttempts(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification id"})
+			return
+		}
+
+		tenantID := middlewares.GetTenantID(c)
+		notification, err := h.notificationService.GetNotification(tenantID, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
 		if err != nil {
 			log.Error("failed to fetch notification",
 				zap.String("id", idStr),
@@ -297,16 +496,169 @@ func (h *NotificationHandler) GetNotification(log *zap.Logger) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch notification"})
 			return
 		}
-		if notification == nil {
+
+		attempts, err := h.attemptService.ListAttempts(tenantID, id)
+		if err != nil {
+			log.Error("failed to fetch delivery attempts",
+				zap.String("id", idStr),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch delivery attempts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"notification_id": notification.ID,
+			"channel":         notification.Channel,
+			"status":          notification.Status,
+			"attempts":        attempts,
+		})
+	}
+}
+
+// ListEvents returns what the provider reported after accepting the
+// notification: deliveries, bounces, opens, clicks and spam reports.
+func (h *NotificationHandler) ListEvents(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idStr := c.Param("id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification id"})
+			return
+		}
+
+		tenantID := middlewares.GetTenantID(c)
+		notification, err := h.notificationService.GetNotification(tenantID, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
 			return
 		}
+		if err != nil {
+			log.Error("failed to fetch notification", zap.String("id", idStr), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch notification"})
+			return
+		}
 
-		c.JSON(http.StatusOK, notification)
+		events, err := h.notificationService.ListEvents(tenantID, id)
+		if err != nil {
+			log.Error("failed to fetch delivery events", zap.String("id", idStr), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch delivery events"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"notification_id": notification.ID,
+			"status":          notification.Status,
+			"events":          events,
+		})
+	}
+}
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+func (h *NotificationHandler) ListNotifications(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := repositories.NotificationFilter{
+			Topic:   c.Query("topic"),
+			Channel: c.Query("channel"),
+			Status:  c.Query("status"),
+			UserRef: c.Query("user_ref"),
+			Limit:   defaultSearchLimit,
+		}
+
+		if v := c.Query("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > maxSearchLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit)})
+				return
+			}
+			filter.Limit = limit
+		}
+		switch c.DefaultQuery("order", "desc") {
+		case "asc":
+			filter.Ascending = true
+		case "desc":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+			return
+		}
+		var err error
+		if filter.CreatedAfter, err = parseTimeQuery(c, "created_after"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if filter.CreatedBefore, err = parseTimeQuery(c, "created_before"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if v := c.Query("cursor"); v != "" {
+			cursor, err := repositories.DecodeNotificationCursor(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			filter.After = cursor
+		}
+
+		page, err := h.notificationService.SearchNotifications(
+			middlewares.GetTenantID(c), filter, c.Query("expand") == "attempts",
+		)
+		if err != nil {
+			log.Error("failed to search notifications", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not search notifications"})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 timestamp", name)
 	}
+	return &t, nil
 }
 
-func (h *NotificationHandler) RedriveNotification(log *zap.Logger, p *kafka.Producer) gin.HandlerFunc {
+// CancelNotification recalls a notification that has not been sent yet,
+// whether it is still held, waiting for a worker or between retries.
+func (h *NotificationHandler) CancelNotification(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification id"})
+			return
+		}
+
+		tenantID := middlewares.GetTenantID(c)
+		err = h.notificationService.Cancel(tenantID, id)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		case errors.Is(err, services.ErrNotCancellable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Error("failed to cancel notification",
+				zap.String("notification_id", id.String()),
+				zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel notification"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "notification cancelled", "notification_id": id})
+	}
+}
+
+func (h *NotificationHandler) RedriveNotification(log *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := uuid.Parse(idStr)
@@ -315,7 +667,8 @@ func (h *NotificationHandler) RedriveNotification(log *zap.Logger, p *kafka.Prod
 			return
 		}
 
-		attempt , err := h.notificationService.DLQNotification(id)
+		tenantID := middlewares.GetTenantID(c)
+		attempt , err := h.notificationService.DLQNotification(tenantID, id)
 		if err != nil {
 			log.Error("failed to fetch DLQ notification",
 				zap.String("notification_id", idStr),
@@ -337,8 +690,8 @@ func (h *NotificationHandler) RedriveNotification(log *zap.Logger, p *kafka.Prod
 			return
 		}
 
-		var msg types.KafkaStreamData
-		if err := json.Unmarshal(attempt.Message, &msg); err != nil {
+		msg, err := types.DecodeDLQMessage(attempt.Message)
+		if err != nil {
 			log.Error("failed to unmarshal DLQ message",
 				zap.String("attempt_id", attempt.ID.String()),
 				zap.Error(err))
@@ -346,17 +699,16 @@ func (h *NotificationHandler) RedriveNotification(log *zap.Logger, p *kafka.Prod
 			return
 		}
 
-		msg.NotificationId = attempt.NotificationID
-
-		err = PublishNotification(
-			c.Request.Context(),
-			p,
-			log,
-			attempt.Channel,
-			"", 
-			msg,
-		)
+		notification, err := h.notificationService.GetNotification(tenantID, attempt.NotificationID)
 		if err != nil {
+			log.Error("failed to fetch notification for redrive",
+				zap.String("notification_id", attempt.NotificationID.String()),
+				zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch notification"})
+			return
+		}
+
+		if err := h.notificationService.Requeue(c.Request.Context(), notification, "", msg); err != nil {
 			log.Error("failed to redrive notification",
 				zap.String("attempt_id", attempt.ID.String()),
 				zap.String("channel", attempt.Channel),
@@ -427,6 +779,161 @@ func splitPayload(data map[string]interface{}, templateData map[string]interface
 	return []normalizedPayload{{RecieverData: data, InTemplateData: templateData}}, nil
 }
 
+// collapsePlan enters the plan's notifications into their collapse windows,
+// one per channel, claimed by the channel's first notification. In suppress
+// mode a notification arriving while a window is open is collapsed into the
+// one that opened it. In replace mode every notification is held until the
+// window closes and takes over from the one held before it, which Enqueue
+// then collapses. An error means Redis could not be reached; the plan is
+// left as it was so the notifications still go out.
+func (h *NotificationHandler) collapsePlan(ctx context.Context, tenantID uuid.UUID, req *types.NotifyRequest, rule models.CollapseRule, plan *notifyPlan, now time.Time) error {
+	window, _ := rule.Duration()
+	mode := rule.ModeOrDefault()
+
+	claims := make(map[string]collapse.Claim)
+	for i := range plan.Items {
+		n := &plan.Items[i].Notification
+		if n.Status == "suppressed" || n.Status == "buffered" {
+			continue
+		}
+		if _, ok := claims[n.Channel]; ok {
+			continue
+		}
+		n.ID = uuid.New()
+		key := collapse.Key(tenantID, req.UserRef, req.EventType, n.Channel, req.CollapseKey)
+		claim, err := h.claimCollapse(ctx, tenantID, key, n.ID, window, mode, now)
+		if err != nil {
+			for j := range plan.Items {
+				plan.Items[j].Notification.ID = uuid.Nil
+			}
+			return errors.Join(err, h.releaseCollapse(ctx, plan))
+		}
+		claims[n.Channel] = claim
+		if mode == models.CollapseReplace || claim.Previous == uuid.Nil {
+			plan.claims = append(plan.claims, collapseClaim{key: key, id: n.ID, previous: claim.Previous})
+		}
+		plan.Items[i].Replace = mode == models.CollapseReplace && claim.Previous != uuid.Nil
+	}
+
+	for i := range plan.Items {
+		n := &plan.Items[i].Notification
+		claim, ok := claims[n.Channel]
+		if !ok || n.Status == "suppressed" || n.Status == "buffered" {
+			continue
+		}
+		n.CollapseKey = req.CollapseKey
+		switch {
+		case mode == models.CollapseReplace:
+			closes := claim.Closes.UTC()
+			if n.SendAt == nil || n.SendAt.Before(closes) {
+				n.SendAt = &closes
+			}
+			if plan.SendAt == nil || plan.SendAt.Before(closes) {
+				plan.SendAt = &closes
+			}
+		case claim.Previous != uuid.Nil:
+			kept := claim.Previous
+			n.Status = "collapsed"
+			n.StatusReason = "collapsed into an earlier notification"
+			n.CollapsedInto = &kept
+			n.SendAt = nil
+		}
+	}
+	return nil
+}
+
+// claimCollapse enters id into the window for key. In suppress mode the
+// notification holding the window must exist: one whose request failed after
+// the claim was never stored, so its window is closed and claimed afresh
+// rather than collapsing into a notification nobody can find.
+func (h *NotificationHandler) claimCollapse(ctx context.Context, tenantID uuid.UUID, key string, id uuid.UUID, window time.Duration, mode string, now time.Time) (collapse.Claim, error) {
+	claim, err := h.collapseStore.Claim(ctx, key, id, window, mode, now)
+	if err != nil || mode == models.CollapseReplace || claim.Previous == uuid.Nil {
+		return claim, err
+	}
+	_, err = h.notificationService.GetNotification(tenantID, claim.Previous)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return claim, err
+	}
+	if err := h.collapseStore.Release(ctx, key, claim.Previous, uuid.Nil); err != nil {
+		return collapse.Claim{}, err
+	}
+	return h.collapseStore.Claim(ctx, key, id, window, mode, now)
+}
+
+// releaseCollapse hands the collapse windows plan claimed back to the
+// notifications that held them before. It is called when the plan is not
+// stored, so no window points at a notification that does not exist.
+func (h *NotificationHandler) releaseCollapse(ctx context.Context, plan *notifyPlan) error {
+	var errs []error
+	for _, c := range plan.claims {
+		if err := h.collapseStore.Release(ctx, c.key, c.id, c.previous); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	plan.claims = nil
+	return errors.Join(errs...)
+}
+
+// abandonPlan releases plan's collapse windows after its request failed.
+func (h *NotificationHandler) abandonPlan(ctx context.Context, log *zap.Logger, plan *notifyPlan) {
+	if err := h.releaseCollapse(ctx, plan); err != nil {
+		log.Warn("failed to release collapse window", zap.Error(err))
+	}
+}
+
+// rejectPayload answers 400 for err, listing every schema violation when err
+// is an *eventschema.ValidationError.
+func rejectPayload(c *gin.Context, err error) {
+	var invalid *eventschema.ValidationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error(), "violations": invalid.Violations})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+func hasRecipient(data map[string]interface{}) bool {
+	switch to := data["to"].(type) {
+	case string:
+		return to != ""
+	case []interface{}:
+		return len(to) > 0
+	}
+	return false
+}
+
+// resolveRecipient fills in "to" for channel from the user's contact.
+func resolveRecipient(data map[string]interface{}, contact *models.Contact, channel, userRef string) error {
+	if contact == nil {
+		return fmt.Errorf("no recipient in data and no contact for user_ref %q", userRef)
+	}
+	addr, ok := contact.Address(channel)
+	if !ok {
+		return fmt.Errorf("contact %q has no %s contact point", userRef, channel)
+	}
+	types.SetRecipient(data, channel, addr)
+	return nil
+}
+
+func notificationIDs(items []services.OutboundNotification) []uuid.UUID {
+	ids := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		ids[i] = item.Notification.ID
+	}
+	return ids
+}
+
+func idsWithStatus(items []services.OutboundNotification, status string) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, item := range items {
+		if item.Notification.Status == status {
+			ids = append(ids, item.Notification.ID)
+		}
+	}
+	return ids
+}
+
 func copyMap(in map[string]interface{}) map[string]interface{} {
 	out := make(map[string]interface{}, len(in))
 	for k, v := range in {
@@ -1,14 +1,66 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/models"
 	"gorm.io/gorm"
 )
 
+type policyRuleRequest struct {
+	Name      string   `json:"name"`
+	Condition string   `json:"condition"`
+	Channels  []string `json:"channels"`
+	Provider  string   `json:"provider"`
+	Priority  int      `json:"priority"`
+}
+
+// policyRequest is the body of both create and update. The topic is only
+// read on create.
+type policyRequest struct {
+	Topic         string   `json:"topic"`
+	Channels      []string `json:"channels" binding:"required"`
+	Locale        string   `json:"locale" binding:"required"`
+	Transactional bool     `json:"transactional"`
+	// Urgent policies ignore quiet hours.
+	Urgent     bool                `json:"urgent"`
+	QuietHours models.QuietWindow  `json:"quiet_hours"`
+	Rules      []policyRuleRequest `json:"rules"`
+	Fallback   []string            `json:"fallback"`
+	Collapse   models.CollapseRule `json:"collapse"`
+	Digest     models.DigestRule   `json:"digest"`
+}
+
+func (r *policyRequest) policy(tenantID uuid.UUID) *models.Policy {
+	policy := &models.Policy{
+		TenantID:      tenantID,
+		Topic:         r.Topic,
+		Channels:      r.Channels,
+		Locale:        r.Locale,
+		Transactional: r.Transactional,
+		Urgent:        r.Urgent,
+		QuietHours:    r.QuietHours,
+		Fallback:      r.Fallback,
+		Collapse:      r.Collapse,
+		Digest:        r.Digest,
+	}
+	for _, rule := range r.Rules {
+		policy.Rules = append(policy.Rules, models.PolicyRule{
+			Name:      rule.Name,
+			Condition: rule.Condition,
+			Channels:  rule.Channels,
+			Provider:  rule.Provider,
+			Priority:  rule.Priority,
+		})
+	}
+	return policy
+}
+
 type PolicyHandler struct {
 	service *services.PolicyService
 }
@@ -17,26 +69,149 @@ func NewPolicyHandler(db *gorm.DB) *PolicyHandler {
 	return &PolicyHandler{service: services.NewPolicyService(db)}
 }
 
-func (h *PolicyHandler) CreatePolicy(c *gin.Context) {
-	var req struct {
-		Topic    string   `json:"topic" binding:"required"`
-		Channels []string `json:"channels" binding:"required"`
-		Locale   string   `json:"locale" binding:"required"`
+// changedBy is the API key behind the request, recorded in policy history.
+func changedBy(c *gin.Context) *uuid.UUID {
+	if key := middlewares.GetAPIKey(c); key != nil {
+		id := key.ID
+		return &id
 	}
+	return nil
+}
+
+func (h *PolicyHandler) CreatePolicy(c *gin.Context) {
+	var req policyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Topic == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "topic is required"})
+		return
+	}
 
-	policy, err := h.service.CreatePolicy(req.Topic, req.Channels, req.Locale)
+	policy := req.policy(middlewares.GetTenantID(c))
+	if err := h.service.CreatePolicy(policy, changedBy(c)); err != nil {
+		if errors.Is(err, services.ErrPolicyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, policy)
+}
+
+func (h *PolicyHandler) ListPolicies(c *gin.Context) {
+	policies, err := h.service.ListPolicies(middlewares.GetTenantID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusCreated, policy)
+	c.JSON(http.StatusOK, policies)
 }
 
+func (h *PolicyHandler) GetPolicy(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Policy ID"})
+		return
+	}
+
+	policy, err := h.service.GetPolicy(middlewares.GetTenantID(c), id)
+	if err != nil {
+		policyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdatePolicy replaces everything but the topic and records a new version.
+func (h *PolicyHandler) UpdatePolicy(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Policy ID"})
+		return
+	}
 
+	var req policyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID := middlewares.GetTenantID(c)
+	current, err := h.service.GetPolicy(tenantID, id)
+	if err != nil {
+		policyError(c, err)
+		return
+	}
+	if req.Topic != "" && req.Topic != current.Topic {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "topic cannot be changed"})
+		return
+	}
+
+	policy := req.policy(tenantID)
+	policy.ID = id
+	if err := h.service.UpdatePolicy(policy, changedBy(c)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			policyError(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.service.GetPolicy(tenantID, id)
+	if err != nil {
+		policyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *PolicyHandler) ListVersions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Policy ID"})
+		return
+	}
+
+	versions, err := h.service.ListVersions(middlewares.GetTenantID(c), id)
+	if err != nil {
+		policyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// RollbackPolicy restores an earlier version. The restore is recorded as a
+// new version, so it can itself be rolled back.
+func (h *PolicyHandler) RollbackPolicy(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Policy ID"})
+		return
+	}
+
+	var req struct {
+		Version int `json:"version" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.service.RollbackPolicy(middlewares.GetTenantID(c), id, req.Version, changedBy(c))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "policy version not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
 
 func (h *PolicyHandler) DeletePolicy(c *gin.Context) {
 	idParam := c.Param("id")
@@ -46,9 +221,17 @@ func (h *PolicyHandler) DeletePolicy(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeletePolicy(id); err != nil {
+	if err := h.service.DeletePolicy(middlewares.GetTenantID(c), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
+
+func policyError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "policy not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
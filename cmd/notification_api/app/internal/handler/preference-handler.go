@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type PreferenceHandler struct {
+	service *services.PreferenceService
+}
+
+func NewPreferenceHandler(db *gorm.DB) *PreferenceHandler {
+	return &PreferenceHandler{service: services.NewPreferenceService(db)}
+}
+
+// SetPreference upserts an opt-in or opt-out. Leave topic or channel empty
+// to cover every topic or channel.
+func (h *PreferenceHandler) SetPreference(c *gin.Context) {
+	var req struct {
+		UserRef string `json:"user_ref" binding:"required"`
+		Topic   string `json:"topic"`
+		Channel string `json:"channel"`
+		OptIn   *bool  `json:"opt_in" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pref := &models.UserPreference{
+		TenantID: middlewares.GetTenantID(c),
+		UserRef:  req.UserRef,
+		Topic:    req.Topic,
+		Channel:  req.Channel,
+		OptIn:    *req.OptIn,
+	}
+	if err := h.service.SetPreference(pref); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pref)
+}
+
+func (h *PreferenceHandler) ListPreferences(c *gin.Context) {
+	prefs, err := h.service.ListPreferences(middlewares.GetTenantID(c), c.Query("user_ref"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+func (h *PreferenceHandler) GetPreference(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid preference ID"})
+		return
+	}
+
+	pref, err := h.service.GetPreference(middlewares.GetTenantID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "preference not found"})
+		return
+	}
+	c.JSON(http.StatusOK, pref)
+}
+
+func (h *PreferenceHandler) UpdatePreference(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid preference ID"})
+		return
+	}
+
+	var req struct {
+		OptIn *bool `json:"opt_in" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID := middlewares.GetTenantID(c)
+	pref := &models.UserPreference{ID: id, TenantID: tenantID, OptIn: *req.OptIn}
+	if err := h.service.UpdatePreference(pref); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "preference not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.service.GetPreference(tenantID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *PreferenceHandler) DeletePreference(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid preference ID"})
+		return
+	}
+
+	if err := h.service.DeletePreference(middlewares.GetTenantID(c), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type QuietHoursHandler struct {
+	service *services.QuietHoursService
+}
+
+func NewQuietHoursHandler(db *gorm.DB) *QuietHoursHandler {
+	return &QuietHoursHandler{service: services.NewQuietHoursService(db)}
+}
+
+// SetQuietHours replaces the user's do-not-disturb window. Timezone is an
+// IANA name such as "Europe/Berlin"; empty means UTC.
+func (h *QuietHoursHandler) SetQuietHours(c *gin.Context) {
+	var req struct {
+		Start    string `json:"start" binding:"required"`
+		End      string `json:"end" binding:"required"`
+		Timezone string `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q := &models.QuietHours{
+		TenantID: middlewares.GetTenantID(c),
+		UserRef:  c.Param("user_ref"),
+		QuietWindow: models.QuietWindow{
+			Start:    req.Start,
+			End:      req.End,
+			Timezone: req.Timezone,
+		},
+	}
+	if err := h.service.SetQuietHours(q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+func (h *QuietHoursHandler) GetQuietHours(c *gin.Context) {
+	q, err := h.service.GetQuietHours(middlewares.GetTenantID(c), c.Param("user_ref"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "quiet hours not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, q)
+}
+
+func (h *QuietHoursHandler) DeleteQuietHours(c *gin.Context) {
+	if err := h.service.DeleteQuietHours(middlewares.GetTenantID(c), c.Param("user_ref")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
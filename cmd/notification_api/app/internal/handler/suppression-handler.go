@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type SuppressionHandler struct {
+	service *services.SuppressionService
+}
+
+func NewSuppressionHandler(db *gorm.DB) *SuppressionHandler {
+	return &SuppressionHandler{service: services.NewSuppressionService(db)}
+}
+
+type suppressionRequest struct {
+	Channel   string     `json:"channel" binding:"required"`
+	Address   string     `json:"address" binding:"required"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (r suppressionRequest) suppression(tenantID uuid.UUID, source string) models.Suppression {
+	return models.Suppression{
+		TenantID:  tenantID,
+		Channel:   r.Channel,
+		Address:   r.Address,
+		Reason:    r.Reason,
+		Source:    source,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
+// AddSuppression suppresses an address. Adding one that is already
+// suppressed replaces its reason and expiry.
+func (h *SuppressionHandler) AddSuppression(c *gin.Context) {
+	var req suppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s := req.suppression(middlewares.GetTenantID(c), "api")
+	if err := h.service.AddSuppression(&s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// ImportSuppressions adds up to services.MaxSuppressionImport suppressions
+// at once. Nothing is stored unless all of them are valid.
+func (h *SuppressionHandler) ImportSuppressions(c *gin.Context) {
+	var req struct {
+		Suppressions []suppressionRequest `json:"suppressions" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID := middlewares.GetTenantID(c)
+	list := make([]models.Suppression, len(req.Suppressions))
+	for i, r := range req.Suppressions {
+		list[i] = r.suppression(tenantID, "import")
+	}
+	if err := h.service.ImportSuppressions(list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"imported": len(list)})
+}
+
+func (h *SuppressionHandler) ListSuppressions(c *gin.Context) {
+	list, err := h.service.ListSuppressions(middlewares.GetTenantID(c), c.Query("channel"), c.Query("reason"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *SuppressionHandler) GetSuppression(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid suppression id"})
+		return
+	}
+	s, err := h.service.GetSuppression(middlewares.GetTenantID(c), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "suppression not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *SuppressionHandler) DeleteSuppression(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid suppression id"})
+		return
+	}
+	if err := h.service.DeleteSuppression(middlewares.GetTenantID(c), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
 	"github.com/jsndz/signalbus/pkg/models"
 	"gorm.io/gorm"
 )
@@ -24,6 +26,7 @@ func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	template.TenantID = middlewares.GetTenantID(c)
 
 	if err := h.service.CreateTemplate(&template); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -41,7 +44,7 @@ func (h *TemplateHandler) GetTemplateByID(c *gin.Context) {
 		return
 	}
 
-	template, err := h.service.GetTemplateByID(id)
+	template, err := h.service.GetTemplateByID(middlewares.GetTenantID(c), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -66,8 +69,13 @@ func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
 		return
 	}
 	template.ID = id
+	template.TenantID = middlewares.GetTenantID(c)
 
 	if err := h.service.UpdateTemplate(&template); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -83,7 +91,7 @@ func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteTemplate(id); err != nil {
+	if err := h.service.DeleteTemplate(middlewares.GetTenantID(c), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type TenantHandler struct {
+	service *services.TenantService
+}
+
+func NewTenantHandler(db *gorm.DB) *TenantHandler {
+	return &TenantHandler{service: services.NewTenantService(db)}
+}
+
+type tenantRequest struct {
+	Name         string `json:"name" binding:"required,max=100"`
+	Plan         string `json:"plan"`
+	QuotaDaily   int    `json:"quota_daily"`
+	QuotaMonthly int    `json:"quota_monthly"`
+}
+
+func (h *TenantHandler) CreateTenant(c *gin.Context) {
+	var req tenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant := models.Tenant{
+		Name:         req.Name,
+		Plan:         req.Plan,
+		QuotaDaily:   req.QuotaDaily,
+		QuotaMonthly: req.QuotaMonthly,
+	}
+	if err := h.service.CreateTenant(&tenant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, tenant)
+}
+
+func (h *TenantHandler) ListTenants(c *gin.Context) {
+	tenants, err := h.service.ListTenants()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tenants)
+}
+
+func (h *TenantHandler) GetTenant(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant ID"})
+		return
+	}
+
+	tenant, err := h.service.GetTenant(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tenant)
+}
+
+func (h *TenantHandler) UpdateTenant(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant ID"})
+		return
+	}
+
+	var req struct {
+		Name         string `json:"name" binding:"max=100"`
+		Plan         string `json:"plan"`
+		QuotaDaily   *int   `json:"quota_daily"`
+		QuotaMonthly *int   `json:"quota_monthly"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, err := h.service.GetTenant(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		return
+	}
+	if req.Name != "" {
+		tenant.Name = req.Name
+	}
+	if req.Plan != "" {
+		tenant.Plan = req.Plan
+	}
+	if req.QuotaDaily != nil {
+		tenant.QuotaDaily = *req.QuotaDaily
+	}
+	if req.QuotaMonthly != nil {
+		tenant.QuotaMonthly = *req.QuotaMonthly
+	}
+
+	if err := h.service.UpdateTenant(tenant); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tenant)
+}
+
+func (h *TenantHandler) DeleteTenant(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant ID"})
+		return
+	}
+
+	if err := h.service.DeleteTenant(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
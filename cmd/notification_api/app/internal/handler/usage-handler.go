@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/middlewares"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type UsageHandler struct {
+	service *services.UsageService
+}
+
+func NewUsageHandler(db *gorm.DB) *UsageHandler {
+	return &UsageHandler{service: services.NewUsageService(db)}
+}
+
+// GetUsage reports the tenant's usage between the from and to days
+// (YYYY-MM-DD, inclusive). It defaults to the current month so far.
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	now := time.Now()
+	from, err := parseDay(c.Query("from"), models.MonthStart(now))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+		return
+	}
+	to, err := parseDay(c.Query("to"), now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+		return
+	}
+
+	report, err := h.service.Report(middlewares.GetTenantID(c), from, to, c.Query("channel"), now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func parseDay(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+func (h *UsageHandler) ListQuotas(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant ID"})
+		return
+	}
+	quotas, err := h.service.ListQuotas(tenantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, quotas)
+}
+
+func (h *UsageHandler) SetQuota(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant ID"})
+		return
+	}
+	var req struct {
+		Monthly int64 `json:"monthly" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quota := models.ChannelQuota{TenantID: tenantID, Channel: c.Param("channel"), Monthly: req.Monthly}
+	if err := h.service.SetQuota(&quota); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, quota)
+}
+
+func (h *UsageHandler) DeleteQuota(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tenant ID"})
+		return
+	}
+	if err := h.service.DeleteQuota(tenantID, c.Param("channel")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// rejectQuota answers with the status of a *services.QuotaError and reports
+// whether err was one.
+func rejectQuota(c *gin.Context, err error) bool {
+	var exceeded *services.QuotaError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+	if exceeded.Status == http.StatusTooManyRequests {
+		retry := int(time.Until(exceeded.Resets).Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(retry))
+	}
+	body := gin.H{
+		"error":  exceeded.Error(),
+		"period": exceeded.Period,
+		"quota":  exceeded.Quota,
+		"used":   exceeded.Used,
+		"resets": exceeded.Resets,
+	}
+	if exceeded.Channel != "" {
+		body["channel"] = exceeded.Channel
+	}
+	c.JSON(exceeded.Status, body)
+	return true
+}
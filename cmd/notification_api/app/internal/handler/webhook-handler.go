@@ -0,0 +1,467 @@
+package handler
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/services"
+	"github.com/jsndz/signalbus/metrics"
+	"github.com/jsndz/signalbus/pkg/fallback"
+	"github.com/jsndz/signalbus/pkg/gosms"
+	"github.com/jsndz/signalbus/pkg/kafka"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/jsndz/signalbus/pkg/utils"
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+	twilioclient "github.com/twilio/twilio-go/client"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// WebhookHandler takes the callbacks providers make after a worker handed
+// them a message. The requests carry no API key; each provider's signature
+// proves where they came from.
+type WebhookHandler struct {
+	notificationService *services.NotificationService
+	suppressionService  *services.SuppressionService
+	inboundService      *services.InboundService
+	producer            *kafka.Producer
+	// twilioToken signs Twilio's requests; twilioStatusURL is the status
+	// callback URL exactly as the SMS worker gave it to Twilio, and
+	// twilioInboundURL the messaging webhook set on the number.
+	twilioToken      string
+	twilioStatusURL  string
+	twilioInboundURL string
+	// replies sends the HELP auto-reply; nil when Twilio is not configured.
+	replies   gosms.Sender
+	helpReply string
+	// sendgridKey verifies SendGrid's signed event webhook; nil refuses
+	// every event.
+	sendgridKey *ecdsa.PublicKey
+}
+
+// defaultHelpReply answers HELP when SMS_HELP_REPLY is not set.
+const defaultHelpReply = "Reply STOP to unsubscribe or START to resubscribe. Msg & data rates may apply."
+
+// NewWebhookHandler reads the providers' verification secrets and the HELP
+// reply from the environment. A SendGrid key that does not parse is logged and leaves the
+// SendGrid webhook refusing requests.
+func NewWebhookHandler(db *gorm.DB, producer *kafka.Producer, log *zap.Logger) *WebhookHandler {
+	h := &WebhookHandler{
+		notificationService: services.NewNotificationService(db),
+		suppressionService:  services.NewSuppressionService(db),
+		inboundService:      services.NewInboundService(db),
+		producer:            producer,
+		twilioToken:         utils.GetEnv("TWILIO_AUTH_TOKEN"),
+		twilioStatusURL:     utils.GetEnv("TWILIO_STATUS_CALLBACK_URL"),
+		twilioInboundURL:    utils.GetEnv("TWILIO_INBOUND_URL"),
+		helpReply:           utils.GetEnv("SMS_HELP_REPLY"),
+	}
+	if h.helpReply == "" {
+		h.helpReply = defaultHelpReply
+	}
+	sid, from := utils.GetEnv("TWILIO_ACCOUNT_SID"), utils.GetEnv("TWILIO_PHONE_NUMBER")
+	if sid != "" && h.twilioToken != "" && from != "" {
+		h.replies = gosms.NewTwilioSender(sid, h.twilioToken, from)
+	}
+	if raw := utils.GetEnv("SENDGRID_WEBHOOK_PUBLIC_KEY"); raw != "" {
+		key, err := parseECDSAKey(raw)
+		if err != nil {
+			log.Error("invalid SENDGRID_WEBHOOK_PUBLIC_KEY", zap.Error(err))
+		}
+		h.sendgridKey = key
+	}
+	return h
+}
+
+// twilioOutcomes maps the message statuses Twilio reports to what the
+// notification becomes; "" records the report without a final outcome.
+var twilioOutcomes = map[string]string{
+	"queued":      "",
+	"sent":        "",
+	"delivered":   "delivered",
+	"undelivered": "failed",
+	"failed":      "failed",
+}
+
+// TwilioStatus handles Twilio's message status callbacks.
+func (h *WebhookHandler) TwilioStatus(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := c.Request.ParseForm(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid form body"})
+			return
+		}
+		if !h.validTwilioSignature(c, h.twilioStatusURL) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid Twilio signature"})
+			return
+		}
+
+		sid := c.PostForm("MessageSid")
+		status := c.PostForm("MessageStatus")
+		if sid == "" || status == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "MessageSid and MessageStatus are required"})
+			return
+		}
+		outcome, ok := twilioOutcomes[status]
+		if !ok {
+			// accepted, scheduled, read and the like say nothing about delivery
+			c.Status(http.StatusNoContent)
+			return
+		}
+
+		receipt := repositories.Receipt{
+			Provider:          "twilio",
+			ProviderMessageID: sid,
+			Status:            status,
+			Outcome:           outcome,
+		}
+		if code := c.PostForm("ErrorCode"); code != "" {
+			receipt.Error = "twilio error " + code
+		}
+		h.applyReceipt(c, log, receipt)
+	}
+}
+
+// applyReceipt stores receipt and, when it reports a failure, hands the
+// notification to the fallback coordinator. A message we never sent is
+// acknowledged all the same, so the provider does not retry it.
+func (h *WebhookHandler) applyReceipt(c *gin.Context, log *zap.Logger, receipt repositories.Receipt) {
+	log = log.With(
+		zap.String("provider", receipt.Provider),
+		zap.String("provider_message_id", receipt.ProviderMessageID),
+		zap.String("status", receipt.Status),
+	)
+	attempt, applied, err := h.notificationService.ApplyReceipt(receipt)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Warn("delivery receipt for an unknown message")
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		log.Error("failed to apply delivery receipt", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply receipt"})
+		return
+	}
+	metrics.DeliveryReceiptsTotal.WithLabelValues(receipt.Provider, receipt.Status).Inc()
+	if applied && receipt.Outcome == "failed" {
+		if err := fallback.Publish(c.Request.Context(), h.producer, attempt.TenantID, attempt.NotificationID,
+			attempt.Channel, receipt.Provider+"_"+receipt.Status); err != nil {
+			log.Error("failed to publish fallback event", zap.Error(err))
+		}
+	}
+	log.Info("delivery receipt applied",
+		zap.String("notification_id", attempt.NotificationID.String()),
+		zap.Bool("duplicate", !applied),
+	)
+	c.Status(http.StatusNoContent)
+}
+
+// validTwilioSignature checks X-Twilio-Signature, an HMAC-SHA1 over the URL
+// Twilio called and the form parameters. Behind a proxy the request's own
+// URL differs from that one, hence configuredURL, which is used instead
+// when set. Without an auth token every request is refused.
+func (h *WebhookHandler) validTwilioSignature(c *gin.Context, configuredURL string) bool {
+	if h.twilioToken == "" {
+		return false
+	}
+	url := configuredURL
+	if url == "" {
+		scheme := "https"
+		if c.Request.TLS == nil && c.GetHeader("X-Forwarded-Proto") != "https" {
+			scheme = "http"
+		}
+		url = scheme + "://" + c.Request.Host + c.Request.URL.RequestURI()
+	}
+	params := make(map[string]string, len(c.Request.PostForm))
+	for k, v := range c.Request.PostForm {
+		if len(v) > 0 {
+			params[k] = v[0]
+		}
+	}
+	validator := twilioclient.NewRequestValidator(h.twilioToken)
+	return validator.Validate(url, params, c.GetHeader("X-Twilio-Signature"))
+}
+
+// TwilioInbound handles the messaging webhook of our Twilio number: texts
+// recipients send us. Every text is stored and published to
+// notification.sms.inbound; STOP and START update the opt-out list before
+// that, and HELP is answered with the configured reply. A text Twilio
+// delivers twice is acknowledged and otherwise ignored.
+func (h *WebhookHandler) TwilioInbound(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := c.Request.ParseForm(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid form body"})
+			return
+		}
+		if !h.validTwilioSignature(c, h.twilioInboundURL) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid Twilio signature"})
+			return
+		}
+
+		msg := &models.InboundSMS{
+			Provider:          "twilio",
+			ProviderMessageID: c.PostForm("MessageSid"),
+			From:              c.PostForm("From"),
+			To:                c.PostForm("To"),
+			Body:              c.PostForm("Body"),
+		}
+		if msg.ProviderMessageID == "" || msg.From == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "MessageSid and From are required"})
+			return
+		}
+		log = log.With(zap.String("provider_message_id", msg.ProviderMessageID))
+
+		created, err := h.inboundService.Receive(c.Request.Context(), msg)
+		var invalid *services.InvalidSenderError
+		if errors.As(err, &invalid) {
+			// answered 400 so Twilio does not retry a text that can never be stored
+			log.Warn("rejected inbound SMS", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Error("failed to receive inbound SMS", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to receive message"})
+			return
+		}
+		if !created {
+			log.Info("duplicate inbound SMS")
+			c.Status(http.StatusNoContent)
+			return
+		}
+		keyword := strings.ToLower(msg.Keyword)
+		if keyword == "" {
+			keyword = "none"
+		}
+		metrics.InboundSMSTotal.WithLabelValues(msg.Provider, keyword).Inc()
+		log.Info("inbound SMS received", zap.String("keyword", keyword))
+
+		if msg.Keyword == gosms.KeywordHelp {
+			h.replyHelp(log, msg.From)
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// replyHelp sends the HELP reply unless the sender is on the platform-wide
+// suppression list, or it cannot be told whether they are. A failure is only
+// logged: the text is already stored, and Twilio would not redeliver it
+// anyway.
+func (h *WebhookHandler) replyHelp(log *zap.Logger, to string) {
+	if h.replies == nil {
+		log.Warn("no SMS sender configured, HELP left unanswered")
+		return
+	}
+	hit, err := h.suppressionService.Suppressed(uuid.Nil, "sms", to, time.Now())
+	if err != nil {
+		log.Error("failed to check suppressions, HELP left unanswered", zap.Error(err))
+		return
+	}
+	if hit != nil {
+		log.Info("sender is suppressed, HELP left unanswered", zap.String("reason", hit.Reason))
+		return
+	}
+	if _, err := h.replies.Send(gosms.NewSMS(to, h.helpReply)); err != nil {
+		log.Error("failed to send HELP reply", zap.Error(err))
+	}
+}
+
+// sendgridEvent is one entry of a SendGrid event webhook batch. Custom args
+// set on the message come back as top-level fields.
+type sendgridEvent struct {
+	Event          string `json:"event"`
+	Email          string `json:"email"`
+	Timestamp      int64  `json:"timestamp"`
+	SGEventID      string `json:"sg_event_id"`
+	SGMessageID    string `json:"sg_message_id"`
+	Reason         string `json:"reason"`
+	Response       string `json:"response"`
+	Type           string `json:"type"`
+	URL            string `json:"url"`
+	NotificationID string `json:"notification_id"`
+	TenantID       string `json:"tenant_id"`
+}
+
+// sendgridOutcomes maps the SendGrid events that settle a notification to
+// its new status. The rest, such as processed, deferred, open and click, are
+// only recorded.
+var sendgridOutcomes = map[string]string{
+	"delivered":  "delivered",
+	"bounce":     "failed",
+	"dropped":    "failed",
+	"spamreport": "spam_reported",
+}
+
+// sendgridSuppressions says which events put the address on the
+// suppression list: hard bounces and spam reports. A bounce of type
+// "blocked" is temporary and does not.
+func sendgridSuppressions(ev sendgridEvent) (string, bool) {
+	switch {
+	case ev.Event == "bounce" && ev.Type != "blocked":
+		return models.SuppressionBounce, true
+	case ev.Event == "spamreport":
+		return models.SuppressionComplaint, true
+	}
+	return "", false
+}
+
+// SendGridEvents handles SendGrid's event webhook. A batch is answered with
+// 500 if any event could not be stored, so SendGrid redelivers it; events
+// already stored are skipped by their sg_event_id.
+func (h *WebhookHandler) SendGridEvents(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not read body"})
+			return
+		}
+		if !h.validSendGridSignature(c, body) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid SendGrid signature"})
+			return
+		}
+
+		var events []sendgridEvent
+		if err := json.Unmarshal(body, &events); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event batch: " + err.Error()})
+			return
+		}
+
+		failed := 0
+		for _, ev := range events {
+			if err := h.recordSendGridEvent(c, log, ev); err != nil {
+				failed++
+				log.Error("failed to record SendGrid event",
+					zap.String("sg_event_id", ev.SGEventID),
+					zap.String("event", ev.Event),
+					zap.Error(err),
+				)
+			}
+		}
+		if failed > 0 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "some events could not be recorded"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *WebhookHandler) recordSendGridEvent(c *gin.Context, log *zap.Logger, ev sendgridEvent) error {
+	if ev.SGEventID == "" || ev.Event == "" {
+		return nil
+	}
+	// sg_message_id is the X-Message-Id the send returned, plus a suffix
+	messageID, _, _ := strings.Cut(ev.SGMessageID, ".")
+
+	target, err := h.sendgridTarget(ev, messageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Warn("SendGrid event for an unknown message",
+			zap.String("sg_event_id", ev.SGEventID), zap.String("sg_message_id", ev.SGMessageID))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reason := ev.Reason
+	if reason == "" {
+		reason = ev.Response
+	}
+	if ev.Type != "" && reason != "" {
+		reason = ev.Type + ": " + reason
+	}
+	// suppress first: a failure here has SendGrid redeliver the event,
+	// which it would not once the event is stored
+	if reason, ok := sendgridSuppressions(ev); ok && ev.Email != "" {
+		if err := h.suppressionService.AddSuppression(&models.Suppression{
+			TenantID: target.TenantID,
+			Channel:  "email",
+			Address:  ev.Email,
+			Reason:   reason,
+			Source:   "sendgrid",
+		}); err != nil {
+			return fmt.Errorf("suppress %s: %w", ev.Email, err)
+		}
+	}
+
+	outcome := sendgridOutcomes[ev.Event]
+	stored, err := h.notificationService.RecordEvent(&models.DeliveryEvent{
+		TenantID:          target.TenantID,
+		NotificationID:    target.ID,
+		Provider:          "sendgrid",
+		ProviderEventID:   ev.SGEventID,
+		ProviderMessageID: messageID,
+		Event:             ev.Event,
+		Reason:            reason,
+		URL:               ev.URL,
+		OccurredAt:        time.Unix(ev.Timestamp, 0).UTC(),
+	}, target.Channel, outcome)
+	if err != nil || !stored {
+		return err
+	}
+	metrics.DeliveryReceiptsTotal.WithLabelValues("sendgrid", ev.Event).Inc()
+	if outcome == "failed" {
+		if err := fallback.Publish(c.Request.Context(), h.producer, target.TenantID, target.ID,
+			target.Channel, "sendgrid_"+ev.Event); err != nil {
+			log.Error("failed to publish fallback event", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// sendgridTarget finds the event's notification through the custom args the
+// email worker sets, or else through the stored message ID.
+func (h *WebhookHandler) sendgridTarget(ev sendgridEvent, messageID string) (*models.Notification, error) {
+	tenantID, tenantErr := uuid.Parse(ev.TenantID)
+	notificationID, idErr := uuid.Parse(ev.NotificationID)
+	if tenantErr == nil && idErr == nil {
+		return h.notificationService.GetNotification(tenantID, notificationID)
+	}
+	if messageID == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	sent, err := h.notificationService.FindSent("sendgrid", messageID)
+	if err != nil {
+		return nil, err
+	}
+	return h.notificationService.GetNotification(sent.TenantID, sent.NotificationID)
+}
+
+// validSendGridSignature checks the ECDSA signature SendGrid puts on the
+// timestamp and raw body of a signed event webhook.
+func (h *WebhookHandler) validSendGridSignature(c *gin.Context, body []byte) bool {
+	signature := c.GetHeader(eventwebhook.VerificationHTTPHeader)
+	timestamp := c.GetHeader(eventwebhook.TimestampHTTPHeader)
+	if h.sendgridKey == nil || signature == "" || timestamp == "" {
+		return false
+	}
+	ok, err := eventwebhook.VerifySignature(h.sendgridKey, body, signature, timestamp)
+	return err == nil && ok
+}
+
+// parseECDSAKey reads the base64 DER public key SendGrid shows for a signed
+// event webhook.
+func parseECDSAKey(raw string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an ECDSA public key")
+	}
+	return ecKey, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/jsndz/signalbus/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const DefaultRotationOverlap = 24 * time.Hour
+
+type APIKeyService struct {
+	repo       *repositories.APIKeyRepository
+	tenantRepo *repositories.TenantRepository
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{
+		repo:       repositories.NewAPIKeyRepository(db),
+		tenantRepo: repositories.NewTenantRepository(db),
+	}
+}
+
+// CreateKey issues a new key for the tenant and returns the plaintext key,
+// which is not stored anywhere and cannot be recovered later.
+func (s *APIKeyService) CreateKey(tenantID uuid.UUID, name string, scopes []string) (string, *models.APIKey, error) {
+	if tenantID == uuid.Nil {
+		return "", nil, errors.New("tenant is required")
+	}
+	if err := validateScopes(scopes); err != nil {
+		return "", nil, err
+	}
+	if _, err := s.tenantRepo.GetByID(tenantID); err != nil {
+		return "", nil, fmt.Errorf("tenant not found: %w", err)
+	}
+
+	raw, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	key := &models.APIKey{
+		TenantID: tenantID,
+		Name:     name,
+		Prefix:   prefix,
+		Hash:     utils.HashAPIKey(raw),
+		Scopes:   scopes,
+	}
+	if err := s.repo.Create(key); err != nil {
+		return "", nil, err
+	}
+	return raw, key, nil
+}
+
+func (s *APIKeyService) ListKeys(tenantID uuid.UUID) ([]models.APIKey, error) {
+	if tenantID == uuid.Nil {
+		return nil, errors.New("tenant is required")
+	}
+	return s.repo.ListByTenant(tenantID)
+}
+
+func (s *APIKeyService) RevokeKey(id uuid.UUID) error {
+	return s.repo.Revoke(id, time.Now())
+}
+
+// RotateKey issues a replacement for the key with the same tenant and scopes.
+// The old key keeps working for the overlap period so callers can roll over.
+func (s *APIKeyService) RotateKey(id uuid.UUID, overlap time.Duration) (string, *models.APIKey, error) {
+	if overlap < 0 {
+		return "", nil, errors.New("overlap cannot be negative")
+	}
+	old, err := s.repo.GetByID(id)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	if old.RevokedAt != nil || (old.ExpiresAt != nil && !old.ExpiresAt.After(now)) {
+		return "", nil, errors.New("cannot rotate a revoked or expired key")
+	}
+
+	raw, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	next := &models.APIKey{
+		TenantID:      old.TenantID,
+		Name:          old.Name,
+		Prefix:        prefix,
+		Hash:          utils.HashAPIKey(raw),
+		Scopes:        old.Scopes,
+		RotatedFromID: &old.ID,
+	}
+	oldExpiresAt := now.Add(overlap)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(oldExpiresAt) {
+		oldExpiresAt = *old.ExpiresAt
+	}
+	if err := s.repo.Rotate(old, next, oldExpiresAt); err != nil {
+		return "", nil, err
+	}
+	return raw, next, nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range models.AllScopes {
+			if s == scope {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+	return nil
+}
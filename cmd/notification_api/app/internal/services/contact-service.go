@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/gosms"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"gorm.io/gorm"
+)
+
+type ContactService struct {
+	repo *repositories.ContactRepository
+}
+
+func NewContactService(db *gorm.DB) *ContactService {
+	return &ContactService{repo: repositories.NewContactRepository(db)}
+}
+
+// SetContact validates and stores c. Phone numbers are normalized to E.164.
+func (s *ContactService) SetContact(c *models.Contact) error {
+	if c.TenantID == uuid.Nil {
+		return errors.New("tenant is required")
+	}
+	if c.UserRef == "" {
+		return errors.New("user_ref is required")
+	}
+	for _, email := range c.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return fmt.Errorf("invalid email %q", email)
+		}
+	}
+	for i, phone := range c.Phones {
+		num, err := gosms.NormalizeSMS(phone)
+		if err != nil {
+			return fmt.Errorf("invalid phone %q: %w", phone, err)
+		}
+		c.Phones[i] = num
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", c.Timezone)
+		}
+	}
+	return s.repo.Upsert(c)
+}
+
+func (s *ContactService) GetContact(tenantID uuid.UUID, userRef string) (*models.Contact, error) {
+	return s.repo.GetByUser(tenantID, userRef)
+}
+
+// FindContact is GetContact for callers that treat a missing contact as
+// normal: it returns nil without an error.
+func (s *ContactService) FindContact(tenantID uuid.UUID, userRef string) (*models.Contact, error) {
+	c, err := s.repo.GetByUser(tenantID, userRef)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return c, err
+}
+
+func (s *ContactService) DeleteContact(tenantID uuid.UUID, userRef string) error {
+	return s.repo.Delete(tenantID, userRef)
+}
@@ -17,12 +17,13 @@ func NewDeliveryAttemptService(db *gorm.DB) *DeliveryAttemptService {
 	return &DeliveryAttemptService{repo: repositories.NewDeliveryAttemptRepository(db)}
 }
 
-func (s *DeliveryAttemptService) CreateAttempt(notificationID uuid.UUID, channel, provider, status, errMsg string, try int, latency int64) (*models.DeliveryAttempt, error) {
+func (s *DeliveryAttemptService) CreateAttempt(tenantID, notificationID uuid.UUID, channel, provider, status, errMsg string, try int, latency int64) (*models.DeliveryAttempt, error) {
 	if channel == "" || provider == "" {
 		return nil, errors.New("channel and provider are required")
 	}
 
 	attempt := &models.DeliveryAttempt{
+		TenantID:       tenantID,
 		NotificationID: notificationID,
 		Channel:        channel,
 		Provider:       provider,
@@ -37,14 +38,14 @@ func (s *DeliveryAttemptService) CreateAttempt(notificationID uuid.UUID, channel
 	return attempt, nil
 }
 
-func (s *DeliveryAttemptService) GetAttempt(id uuid.UUID) (*models.DeliveryAttempt, error) {
-	return s.repo.GetByID(id)
+func (s *DeliveryAttemptService) GetAttempt(tenantID, id uuid.UUID) (*models.DeliveryAttempt, error) {
+	return s.repo.GetByID(tenantID, id)
 }
 
-func (s *DeliveryAttemptService) ListAttempts(notificationID uuid.UUID) ([]models.DeliveryAttempt, error) {
-	return s.repo.ListByNotification(notificationID)
+func (s *DeliveryAttemptService) ListAttempts(tenantID, notificationID uuid.UUID) ([]models.DeliveryAttempt, error) {
+	return s.repo.ListByNotification(tenantID, notificationID)
 }
 
-func (s *DeliveryAttemptService) DeleteAttempt(id uuid.UUID) error {
-	return s.repo.Delete(id)
+func (s *DeliveryAttemptService) DeleteAttempt(tenantID, id uuid.UUID) error {
+	return s.repo.Delete(tenantID, id)
 }
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/eventschema"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"gorm.io/gorm"
+)
+
+// ErrSchemaExists is returned when the event type already has a schema.
+var ErrSchemaExists = errors.New("a schema for this event type already exists")
+
+type EventSchemaService struct {
+	repo *repositories.EventSchemaRepository
+}
+
+func NewEventSchemaService(db *gorm.DB) *EventSchemaService {
+	return &EventSchemaService{repo: repositories.NewEventSchemaRepository(db)}
+}
+
+func (s *EventSchemaService) CreateSchema(schema *models.EventSchema) error {
+	if schema.TenantID == uuid.Nil {
+		return errors.New("tenant is required")
+	}
+	if schema.EventType == "" {
+		return errors.New("event_type is required")
+	}
+	if _, err := eventschema.Compile(string(schema.Schema)); err != nil {
+		return err
+	}
+	_, err := s.repo.GetByEventType(schema.TenantID, schema.EventType)
+	if err == nil {
+		return ErrSchemaExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return s.repo.Create(schema)
+}
+
+func (s *EventSchemaService) UpdateSchema(tenantID uuid.UUID, eventType string, schema models.JSONDocument) error {
+	if _, err := eventschema.Compile(string(schema)); err != nil {
+		return err
+	}
+	return s.repo.UpdateSchema(tenantID, eventType, schema)
+}
+
+func (s *EventSchemaService) GetSchema(tenantID uuid.UUID, eventType string) (*models.EventSchema, error) {
+	return s.repo.GetByEventType(tenantID, eventType)
+}
+
+func (s *EventSchemaService) ListSchemas(tenantID uuid.UUID) ([]models.EventSchema, error) {
+	return s.repo.List(tenantID)
+}
+
+func (s *EventSchemaService) DeleteSchema(tenantID uuid.UUID, eventType string) error {
+	return s.repo.Delete(tenantID, eventType)
+}
+
+// Validate checks a payload against the event type's schema. Event types
+// without a schema accept anything. A mismatch is returned as
+// *eventschema.ValidationError.
+func (s *EventSchemaService) Validate(tenantID uuid.UUID, eventType string, data, templateData map[string]interface{}) error {
+	stored, err := s.repo.GetByEventType(tenantID, eventType)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return validateAgainst(stored, data, templateData)
+}
+
+// DryRun is Validate for an event type that must have a schema; it returns
+// gorm.ErrRecordNotFound otherwise.
+func (s *EventSchemaService) DryRun(tenantID uuid.UUID, eventType string, data, templateData map[string]interface{}) error {
+	stored, err := s.repo.GetByEventType(tenantID, eventType)
+	if err != nil {
+		return err
+	}
+	return validateAgainst(stored, data, templateData)
+}
+
+func validateAgainst(stored *models.EventSchema, data, templateData map[string]interface{}) error {
+	schema, err := eventschema.Compile(string(stored.Schema))
+	if err != nil {
+		return err
+	}
+	return schema.Validate(stored.EventType, data, templateData)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/gosms"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/outbox"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"gorm.io/gorm"
+)
+
+type InboundService struct {
+	repo *repositories.InboundRepository
+}
+
+func NewInboundService(db *gorm.DB) *InboundService {
+	return &InboundService{repo: repositories.NewInboundRepository(db)}
+}
+
+// InvalidSenderError is returned by Receive when the text's sender is not a
+// phone number it can store.
+type InvalidSenderError struct {
+	From string
+	Err  error
+}
+
+func (e *InvalidSenderError) Error() string {
+	return fmt.Sprintf("invalid sender %q: %v", e.From, e.Err)
+}
+
+func (e *InvalidSenderError) Unwrap() error { return e.Err }
+
+// Receive stores an inbound text and publishes it through the outbox. STOP
+// and its synonyms put the sender on the platform-wide suppression list and
+// START takes them off it, in the same transaction. A text the provider
+// delivered before is ignored; Receive reports whether msg was new. A sender
+// that does not normalize yields an *InvalidSenderError.
+func (s *InboundService) Receive(ctx context.Context, msg *models.InboundSMS) (bool, error) {
+	from, err := gosms.NormalizeSMS(msg.From)
+	if err != nil {
+		return false, &InvalidSenderError{From: msg.From, Err: err}
+	}
+	msg.From = from
+	msg.Keyword = gosms.Keyword(msg.Body)
+
+	headers, err := outbox.TraceHeaders(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	created := false
+	err = s.repo.Transaction(func(tx *repositories.InboundRepository) error {
+		ok, err := tx.Create(msg)
+		if err != nil || !ok {
+			return err
+		}
+		created = true
+
+		switch msg.Keyword {
+		case gosms.KeywordStop:
+			err = tx.Suppressions().Upsert(&models.Suppression{
+				TenantID: uuid.Nil,
+				Channel:  "sms",
+				Address:  msg.From,
+				Reason:   models.SuppressionUnsubscribe,
+				Source:   msg.Provider,
+			})
+		case gosms.KeywordStart:
+			err = tx.Suppressions().Lift(uuid.Nil, "sms", msg.From, models.SuppressionUnsubscribe)
+		}
+		if err != nil {
+			return err
+		}
+
+		out, err := outbox.NewInboundMessage(msg, headers)
+		if err != nil {
+			return err
+		}
+		return tx.CreateOutbox(out)
+	})
+	return created, err
+}
@@ -1,11 +1,14 @@
 package services
 
 import (
+	"context"
 	"errors"
 
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/outbox"
 	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/jsndz/signalbus/pkg/types"
 	"gorm.io/gorm"
 )
 
@@ -17,11 +20,15 @@ func NewNotificationService(db *gorm.DB) *NotificationService {
 	return &NotificationService{repo: repositories.NewNotificationRepository(db)}
 }
 
-func (s *NotificationService) CreateNotification(topic, channel, userRef string) (uuid.UUID, error) {
+func (s *NotificationService) CreateNotification(tenantID uuid.UUID, topic, channel, userRef string) (uuid.UUID, error) {
+	if tenantID == uuid.Nil {
+		return uuid.Nil, errors.New("tenant is required")
+	}
 	if topic == "" {
 		return uuid.Nil, errors.New("notification topic cannot be empty")
 	}
 	notification := &models.Notification{
+		TenantID: tenantID,
 		Channel: channel,
 		Topic:    topic,
 		UserRef:  userRef,
@@ -33,22 +40,246 @@ func (s *NotificationService) CreateNotification(topic, channel, userRef string)
 	return notification.ID, nil
 }
 
-func (s *NotificationService) GetNotification(id uuid.UUID) (*models.Notification, error) {
-	return s.repo.GetByID(id)
+// OutboundNotification is a notification row together with the Kafka message
+// that hands it to its channel worker.
+type OutboundNotification struct {
+	Notification models.Notification
+	Key          string
+	Message      types.KafkaStreamData
+	// Replace collapses the held notifications of the same collapse group
+	// into this one.
+	Replace bool
+	// Digest buffers the notification for its topic's digest instead of
+	// sending it.
+	Digest *models.DigestItem
+}
+
+// Enqueue stores every notification and its outbox record in one
+// transaction. Notifications created with a final status such as suppressed
+// or collapsed, and those buffered for a digest, get no outbox record. Nothing is published here; outbox.Relay picks the records up
+// after commit, so an accepted notification always reaches Kafka eventually.
+// Accepted notifications are counted in the usage ledger.
+func (s *NotificationService) Enqueue(ctx context.Context, items []OutboundNotification) error {
+	headers, err := outbox.TraceHeaders(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Transaction(func(tx *repositories.NotificationRepository) error {
+		accepted := make(map[uuid.UUID]map[string]int64)
+		for i := range items {
+			item := &items[i]
+			n := &item.Notification
+			if n.TenantID == uuid.Nil {
+				return errors.New("tenant is required")
+			}
+			if n.Topic == "" {
+				return errors.New("notification topic cannot be empty")
+			}
+			if n.Status == "" {
+				n.Status = "pending"
+				if n.SendAt != nil {
+					n.Status = "scheduled"
+				}
+			}
+			if err := tx.Create(n); err != nil {
+				return err
+			}
+			if item.Replace {
+				if err := tx.CollapseHeld(n); err != nil {
+					return err
+				}
+			}
+			if item.Digest != nil {
+				item.Digest.TenantID = n.TenantID
+				item.Digest.UserRef = n.UserRef
+				item.Digest.Topic = n.Topic
+				item.Digest.Channel = n.Channel
+				item.Digest.NotificationID = n.ID
+				if err := tx.CreateDigestItem(item.Digest); err != nil {
+					return err
+				}
+			}
+			if Accepted(n.Status) {
+				if accepted[n.TenantID] == nil {
+					accepted[n.TenantID] = make(map[string]int64)
+				}
+				accepted[n.TenantID][n.Channel]++
+			}
+			if !dispatchable(n.Status) {
+				// Recorded for the audit trail only; nothing to publish.
+				continue
+			}
+
+			item.Message.TenantID = n.TenantID
+			item.Message.NotificationId = n.ID
+			msg, err := outbox.NewMessage(n, item.Key, item.Message, headers)
+			if err != nil {
+				return err
+			}
+			if n.SendAt != nil {
+				// held back until the scheduler releases it
+				msg.Status = "scheduled"
+				msg.AvailableAt = *n.SendAt
+			}
+			if err := tx.CreateOutbox(msg); err != nil {
+				return err
+			}
+		}
+		for tenantID, channels := range accepted {
+			for channel, count := range channels {
+				if err := tx.RecordAccepted(tenantID, channel, count); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Requeue puts an existing notification back to pending and writes a new
+// outbox record for it.
+func (s *NotificationService) Requeue(ctx context.Context, n *models.Notification, key string, msg types.KafkaStreamData) error {
+	headers, err := outbox.TraceHeaders(ctx)
+	if err != nil {
+		return err
+	}
+	msg.TenantID = n.TenantID
+	msg.NotificationId = n.ID
+	outboxMsg, err := outbox.NewMessage(n, key, msg, headers)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Transaction(func(tx *repositories.NotificationRepository) error {
+		if err := tx.UpdateStatus(n.TenantID, n.ID, "pending"); err != nil {
+			return err
+		}
+		return tx.CreateOutbox(outboxMsg)
+	})
 }
 
-func (s *NotificationService) ListNotifications() ([]models.Notification, error) {
-	return s.repo.List()
+// dispatchable reports whether a notification created with status still has
+// to be handed to a worker.
+func dispatchable(status string) bool {
+	return status == "pending" || status == "scheduled" || status == "deferred"
+}
+
+// Accepted reports whether a notification created with status counts
+// against the tenant's usage: it will be sent, on its own or in a digest.
+func Accepted(status string) bool {
+	return dispatchable(status) || status == "buffered"
+}
+
+// ErrNotCancellable is returned when cancelling a notification that was
+// already delivered, failed or otherwise finished.
+var ErrNotCancellable = errors.New("notification can no longer be cancelled")
+
+// Cancel stops a pending, scheduled, deferred or retrying notification
+// before it is sent.
+func (s *NotificationService) Cancel(tenantID, id uuid.UUID) error {
+	return s.repo.Transaction(func(tx *repositories.NotificationRepository) error {
+		cancelled, err := tx.Cancel(tenantID, id)
+		if err != nil {
+			return err
+		}
+		if !cancelled {
+			return ErrNotCancellable
+		}
+		return nil
+	})
+}
+
+// ApplyReceipt records a provider's delivery report; see
+// repositories.NotificationRepository.ApplyReceipt.
+func (s *NotificationService) ApplyReceipt(receipt repositories.Receipt) (*models.DeliveryAttempt, bool, error) {
+	return s.repo.ApplyReceipt(receipt)
+}
+
+// FindSent returns the attempt that handed the provider its message.
+func (s *NotificationService) FindSent(provider, providerMessageID string) (*models.DeliveryAttempt, error) {
+	return s.repo.FindSent(provider, providerMessageID)
+}
+
+func (s *NotificationService) RecordEvent(event *models.DeliveryEvent, channel, outcome string) (bool, error) {
+	return s.repo.RecordEvent(event, channel, outcome)
+}
+
+func (s *NotificationService) ListEvents(tenantID, notificationID uuid.UUID) ([]models.DeliveryEvent, error) {
+	return s.repo.ListEvents(tenantID, notificationID)
+}
+
+func (s *NotificationService) GetNotification(tenantID, id uuid.UUID) (*models.Notification, error) {
+	return s.repo.GetByID(tenantID, id)
+}
+
+func (s *NotificationService) ListNotifications(tenantID uuid.UUID) ([]models.Notification, error) {
+	return s.repo.List(tenantID)
+}
+
+// NotificationResult is a notification as returned by the API, optionally
+// carrying its delivery attempts.
+type NotificationResult struct {
+	models.Notification
+	Attempts []models.DeliveryAttempt `json:",omitempty"`
+}
+
+type NotificationPage struct {
+	Items      []NotificationResult `json:"items"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+func (s *NotificationService) SearchNotifications(tenantID uuid.UUID, filter repositories.NotificationFilter, withAttempts bool) (*NotificationPage, error) {
+	if filter.Limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	// fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+	rows, err := s.repo.Search(tenantID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &NotificationPage{Items: make([]NotificationResult, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = repositories.NotificationCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	byID := make(map[uuid.UUID][]models.DeliveryAttempt)
+	if withAttempts {
+		ids := make([]uuid.UUID, len(rows))
+		for i, n := range rows {
+			ids[i] = n.ID
+		}
+		attempts, err := s.repo.ListAttemptsByNotifications(tenantID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attempts {
+			byID[a.NotificationID] = append(byID[a.NotificationID], a)
+		}
+	}
+	for _, n := range rows {
+		result := NotificationResult{Notification: n}
+		if withAttempts {
+			result.Attempts = byID[n.ID]
+		}
+		page.Items = append(page.Items, result)
+	}
+	return page, nil
 }
 
-func (s *NotificationService) DeleteNotification(id uuid.UUID) error {
-	return s.repo.Delete(id)
+func (s *NotificationService) DeleteNotification(tenantID, id uuid.UUID) error {
+	return s.repo.Delete(tenantID, id)
 }
 
-func (s *NotificationService) UpdateStatus(id uuid.UUID, status string) error {
-	return s.repo.UpdateStatus(id, status)
+func (s *NotificationService) UpdateStatus(tenantID, id uuid.UUID, status string) error {
+	return s.repo.UpdateStatus(tenantID, id, status)
 }
 
-func (s *NotificationService) DLQNotification(id uuid.UUID) (*models.DeliveryAttempt, error) {
-	return s.repo.GetDLQByNotificationID(id)
+func (s *NotificationService) DLQNotification(tenantID, id uuid.UUID) (*models.DeliveryAttempt, error) {
+	return s.repo.GetDLQByNotificationID(tenantID, id)
 }
@@ -2,13 +2,19 @@ package services
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/pkg/models"
 	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/jsndz/signalbus/pkg/rules"
 	"gorm.io/gorm"
 )
 
+// ErrPolicyExists is returned when the tenant already has a policy for the
+// topic.
+var ErrPolicyExists = errors.New("a policy for this topic already exists")
+
 type PolicyService struct {
 	repo *repositories.PolicyRepository
 }
@@ -17,34 +23,90 @@ func NewPolicyService(db *gorm.DB) *PolicyService {
 	return &PolicyService{repo: repositories.NewPolicyRepository(db)}
 }
 
-func (s *PolicyService) CreatePolicy( topic string, channels []string, locale string) (*models.Policy, error) {
-	
-	if topic == "" {
-		return nil, errors.New("topic is required")
+// CreatePolicy stores policy as version 1. changedBy is the API key making
+// the request and may be nil.
+func (s *PolicyService) CreatePolicy(policy *models.Policy, changedBy *uuid.UUID) error {
+	if policy.TenantID == uuid.Nil {
+		return errors.New("tenant is required")
+	}
+	if policy.Topic == "" {
+		return errors.New("topic is required")
+	}
+	if err := validatePolicy(policy); err != nil {
+		return err
 	}
-	if len(channels) == 0 {
-		return nil, errors.New("at least one channel is required")
+	_, err := s.repo.GetByTopic(policy.TenantID, policy.Topic)
+	if err == nil {
+		return ErrPolicyExists
 	}
-	if locale == "" {
-		return nil, errors.New("locale is required")
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
 	}
+	return s.repo.Create(policy, changedBy)
+}
 
-	policy := &models.Policy{
-		Topic:    topic,
-		Channels: channels,
-		Locale:   locale,
+// UpdatePolicy replaces the settings and rules of an existing policy. The
+// topic cannot change.
+func (s *PolicyService) UpdatePolicy(policy *models.Policy, changedBy *uuid.UUID) error {
+	if err := validatePolicy(policy); err != nil {
+		return err
 	}
-	if err := s.repo.Create(policy); err != nil {
+	return s.repo.Update(policy, "updated", changedBy)
+}
+
+// RollbackPolicy restores the policy as it stood at version. The rollback is
+// itself recorded as a new version.
+func (s *PolicyService) RollbackPolicy(tenantID, id uuid.UUID, version int, changedBy *uuid.UUID) (*models.Policy, error) {
+	old, err := s.repo.GetVersion(tenantID, id, version)
+	if err != nil {
 		return nil, err
 	}
-	return policy, nil
+	old.ID = id
+	old.TenantID = tenantID
+	if err := s.repo.Update(old, fmt.Sprintf("rolled back to v%d", version), changedBy); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(tenantID, id)
+}
+
+func validatePolicy(policy *models.Policy) error {
+	if len(policy.Channels) == 0 {
+		return errors.New("at least one channel is required")
+	}
+	if policy.Locale == "" {
+		return errors.New("locale is required")
+	}
+	if err := policy.QuietHours.Validate(); err != nil {
+		return fmt.Errorf("quiet_hours: %w", err)
+	}
+	if err := policy.Collapse.Validate(); err != nil {
+		return fmt.Errorf("collapse: %w", err)
+	}
+	if err := policy.Digest.Validate(); err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+	return rules.Validate(policy)
+}
+
+func (s *PolicyService) GetPolicyByTopic(tenantID uuid.UUID, topic string) (*models.Policy, error) {
+	return s.repo.GetByTopic(tenantID, topic)
 }
 
-func (s *PolicyService) GetPolicyByTopic(topic string) (*models.Policy, error) {
-	return s.repo.GetByTopic(topic)
+func (s *PolicyService) GetPolicy(tenantID, id uuid.UUID) (*models.Policy, error) {
+	return s.repo.GetByID(tenantID, id)
 }
 
+func (s *PolicyService) ListPolicies(tenantID uuid.UUID) ([]models.Policy, error) {
+	return s.repo.List(tenantID)
+}
+
+func (s *PolicyService) ListVersions(tenantID, id uuid.UUID) ([]models.PolicyVersion, error) {
+	if _, err := s.repo.GetByID(tenantID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListVersions(tenantID, id)
+}
 
-func (s *PolicyService) DeletePolicy(id uuid.UUID) error {
-	return s.repo.Delete(id)
+func (s *PolicyService) DeletePolicy(tenantID, id uuid.UUID) error {
+	return s.repo.Delete(tenantID, id)
 }
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"gorm.io/gorm"
+)
+
+type PreferenceService struct {
+	repo *repositories.PreferenceRepository
+}
+
+func NewPreferenceService(db *gorm.DB) *PreferenceService {
+	return &PreferenceService{repo: repositories.NewPreferenceRepository(db)}
+}
+
+// SetPreference stores an opt-in or opt-out, replacing any earlier one for
+// the same user, topic and channel.
+func (s *PreferenceService) SetPreference(pref *models.UserPreference) error {
+	if pref.TenantID == uuid.Nil {
+		return errors.New("tenant is required")
+	}
+	if pref.UserRef == "" {
+		return errors.New("user_ref is required")
+	}
+	return s.repo.Upsert(pref)
+}
+
+func (s *PreferenceService) GetPreference(tenantID, id uuid.UUID) (*models.UserPreference, error) {
+	return s.repo.GetByID(tenantID, id)
+}
+
+func (s *PreferenceService) ListPreferences(tenantID uuid.UUID, userRef string) (models.UserPreferences, error) {
+	if userRef == "" {
+		return nil, errors.New("user_ref is required")
+	}
+	return s.repo.ListByUser(tenantID, userRef)
+}
+
+func (s *PreferenceService) UpdatePreference(pref *models.UserPreference) error {
+	return s.repo.Update(pref)
+}
+
+func (s *PreferenceService) DeletePreference(tenantID, id uuid.UUID) error {
+	return s.repo.Delete(tenantID, id)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"gorm.io/gorm"
+)
+
+type QuietHoursService struct {
+	repo *repositories.QuietHoursRepository
+}
+
+func NewQuietHoursService(db *gorm.DB) *QuietHoursService {
+	return &QuietHoursService{repo: repositories.NewQuietHoursRepository(db)}
+}
+
+func (s *QuietHoursService) SetQuietHours(q *models.QuietHours) error {
+	if q.TenantID == uuid.Nil {
+		return errors.New("tenant is required")
+	}
+	if q.UserRef == "" {
+		return errors.New("user_ref is required")
+	}
+	if q.Start == "" || q.End == "" {
+		return errors.New("start and end are required")
+	}
+	if err := q.QuietWindow.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Upsert(q)
+}
+
+func (s *QuietHoursService) GetQuietHours(tenantID uuid.UUID, userRef string) (*models.QuietHours, error) {
+	return s.repo.GetByUser(tenantID, userRef)
+}
+
+// EffectiveWindow returns the user's own window, falling back to the policy
+// default when the user has none.
+func (s *QuietHoursService) EffectiveWindow(tenantID uuid.UUID, userRef string, policy *models.Policy) (models.QuietWindow, error) {
+	return s.repo.EffectiveWindow(tenantID, userRef, policy)
+}
+
+func (s *QuietHoursService) DeleteQuietHours(tenantID uuid.UUID, userRef string) error {
+	return s.repo.Delete(tenantID, userRef)
+}
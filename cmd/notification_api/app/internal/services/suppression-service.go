@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/gosms"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"gorm.io/gorm"
+)
+
+// MaxSuppressionImport is how many suppressions one import may carry.
+const MaxSuppressionImport = 1000
+
+type SuppressionService struct {
+	repo *repositories.SuppressionRepository
+}
+
+func NewSuppressionService(db *gorm.DB) *SuppressionService {
+	return &SuppressionService{repo: repositories.NewSuppressionRepository(db)}
+}
+
+// validateSuppression checks s and puts its address in the form it is
+// matched in. A missing reason means manual.
+func validateSuppression(s *models.Suppression, now time.Time) error {
+	if s.TenantID == uuid.Nil {
+		return errors.New("tenant is required")
+	}
+	switch s.Channel {
+	case "email":
+		if _, err := mail.ParseAddress(s.Address); err != nil {
+			return fmt.Errorf("invalid email %q", s.Address)
+		}
+	case "sms":
+		num, err := gosms.NormalizeSMS(s.Address)
+		if err != nil {
+			return fmt.Errorf("invalid phone %q: %w", s.Address, err)
+		}
+		s.Address = num
+	default:
+		return errors.New("channel must be email or sms")
+	}
+	s.Address = models.SuppressionAddress(s.Channel, s.Address)
+
+	if s.Reason == "" {
+		s.Reason = models.SuppressionManual
+	}
+	if !models.ValidSuppressionReason(s.Reason) {
+		return fmt.Errorf("unknown reason %q", s.Reason)
+	}
+	if s.ExpiresAt != nil && !s.ExpiresAt.After(now) {
+		return errors.New("expires_at must be in the future")
+	}
+	return nil
+}
+
+// AddSuppression validates and stores s, renewing an existing suppression
+// of the same address.
+func (s *SuppressionService) AddSuppression(sup *models.Suppression) error {
+	if err := validateSuppression(sup, time.Now()); err != nil {
+		return err
+	}
+	return s.repo.Upsert(sup)
+}
+
+// ImportSuppressions validates every suppression and stores them all, or
+// none if any is invalid. When an address appears twice the last one wins.
+func (s *SuppressionService) ImportSuppressions(list []models.Suppression) error {
+	if len(list) == 0 {
+		return errors.New("no suppressions to import")
+	}
+	if len(list) > MaxSuppressionImport {
+		return fmt.Errorf("an import may carry at most %d suppressions", MaxSuppressionImport)
+	}
+	now := time.Now()
+	seen := make(map[string]int, len(list))
+	unique := make([]models.Suppression, 0, len(list))
+	for i := range list {
+		if err := validateSuppression(&list[i], now); err != nil {
+			return fmt.Errorf("suppression %d: %w", i, err)
+		}
+		key := list[i].Channel + ":" + list[i].Address
+		if j, ok := seen[key]; ok {
+			unique[j] = list[i]
+			continue
+		}
+		seen[key] = len(unique)
+		unique = append(unique, list[i])
+	}
+	return s.repo.Import(unique)
+}
+
+func (s *SuppressionService) GetSuppression(tenantID, id uuid.UUID) (*models.Suppression, error) {
+	return s.repo.GetByID(tenantID, id)
+}
+
+func (s *SuppressionService) ListSuppressions(tenantID uuid.UUID, channel, reason string) ([]models.Suppression, error) {
+	return s.repo.List(tenantID, channel, reason)
+}
+
+// Suppressed returns the suppression in force for address on channel, or
+// nil when it may be sent to.
+func (s *SuppressionService) Suppressed(tenantID uuid.UUID, channel, address string, now time.Time) (*models.Suppression, error) {
+	_, hit, err := s.repo.Filter(tenantID, channel, []string{address}, now)
+	return hit, err
+}
+
+func (s *SuppressionService) DeleteSuppression(tenantID, id uuid.UUID) error {
+	return s.repo.Delete(tenantID, id)
+}
+
+// Apply drops suppressed recipients from the notifications about to be
+// accepted. A notification left with no recipient is suppressed instead of
+// sent, with the reason of the suppression that applied.
+func (s *SuppressionService) Apply(tenantID uuid.UUID, items []OutboundNotification, now time.Time) error {
+	byChannel := make(map[string][]string)
+	for _, item := range items {
+		if !suppressible(item) {
+			continue
+		}
+		channel := item.Notification.Channel
+		for _, addr := range recipients(item.Message.RecieverData) {
+			byChannel[channel] = append(byChannel[channel], models.SuppressionAddress(channel, addr))
+		}
+	}
+	matches := make(map[string]map[string]models.Suppression, len(byChannel))
+	for channel, addresses := range byChannel {
+		m, err := s.repo.Matching(tenantID, channel, addresses, now)
+		if err != nil {
+			return err
+		}
+		matches[channel] = m
+	}
+
+	for i := range items {
+		item := &items[i]
+		if !suppressible(*item) {
+			continue
+		}
+		channel := item.Notification.Channel
+		var hit *models.Suppression
+		var kept []interface{}
+		for _, addr := range recipients(item.Message.RecieverData) {
+			if sup, ok := matches[channel][models.SuppressionAddress(channel, addr)]; ok {
+				if hit == nil {
+					hit = &sup
+				}
+				continue
+			}
+			kept = append(kept, addr)
+		}
+		if hit == nil {
+			continue
+		}
+		if len(kept) > 0 {
+			// a digest item shares the map, so it loses the address too
+			item.Message.RecieverData["to"] = kept
+			continue
+		}
+		n := &item.Notification
+		n.Status = "suppressed"
+		n.StatusReason = "recipient suppressed: " + hit.Reason
+		n.SendAt = nil
+		item.Digest = nil
+	}
+	return nil
+}
+
+func suppressible(item OutboundNotification) bool {
+	status := item.Notification.Status
+	return status == "" || Accepted(status)
+}
+
+// recipients returns the addresses in data's "to", a single SMS number or a
+// list of email addresses.
+func recipients(data map[string]interface{}) []string {
+	switch to := data["to"].(type) {
+	case string:
+		return []string{to}
+	case []string:
+		return to
+	case []interface{}:
+		addrs := make([]string, 0, len(to))
+		for _, v := range to {
+			if addr, ok := v.(string); ok {
+				addrs = append(addrs, addr)
+			}
+		}
+		return addrs
+	}
+	return nil
+}
@@ -18,7 +18,9 @@ func NewTemplateService(db *gorm.DB) *TemplateService {
 }
 
 func (s *TemplateService) CreateTemplate(template *models.Template) error {
-	
+	if template.TenantID == uuid.Nil {
+		return errors.New("tenant is required")
+	}
 	if template.Name == "" {
 		return errors.New("template name is required")
 	}
@@ -39,14 +41,14 @@ func (s *TemplateService) CreateTemplate(template *models.Template) error {
 	return s.repo.Create(template)
 }
 
-func (s *TemplateService) GetTemplateByID(id uuid.UUID) (*models.Template, error) {
+func (s *TemplateService) GetTemplateByID(tenantID, id uuid.UUID) (*models.Template, error) {
 	if id == uuid.Nil {
 		return nil, errors.New("invalid template ID")
 	}
-	return s.repo.GetByID(id)
+	return s.repo.GetByID(tenantID, id)
 }
 
-func (s *TemplateService) GetTemplateByName( name, locale string) (*models.Template, error) {
+func (s *TemplateService) GetTemplateByName(tenantID uuid.UUID, name, locale string) (*models.Template, error) {
 
 	if name == "" {
 		return nil, errors.New("template name is required")
@@ -54,7 +56,7 @@ func (s *TemplateService) GetTemplateByName( name, locale string) (*models.Templ
 	if locale == "" {
 		locale = "en-US"
 	}
-	return s.repo.GetByName(name, locale)
+	return s.repo.GetByName(tenantID, name, locale)
 }
 
 func (s *TemplateService) UpdateTemplate(template *models.Template) error {
@@ -64,14 +66,14 @@ func (s *TemplateService) UpdateTemplate(template *models.Template) error {
 	return s.repo.Update(template)
 }
 
-func (s *TemplateService) DeleteTemplate(id uuid.UUID) error {
+func (s *TemplateService) DeleteTemplate(tenantID, id uuid.UUID) error {
 	if id == uuid.Nil {
 		return errors.New("invalid template ID")
 	}
-	return s.repo.Delete(id)
+	return s.repo.Delete(tenantID, id)
 }
 
-func (s *TemplateService) LookupTemplate( channel, name, locale, contentType string) (*models.Template, error) {
+func (s *TemplateService) LookupTemplate(tenantID uuid.UUID, channel, name, locale, contentType string) (*models.Template, error) {
 	
 	if channel == "" || name == "" || contentType == "" {
 		return nil, errors.New("channel, name and content type are required")
@@ -79,5 +81,5 @@ func (s *TemplateService) LookupTemplate( channel, name, locale, contentType str
 	if locale == "" {
 		locale = "en-US"
 	}
-	return s.repo.GetByLookup(channel, name, locale, contentType)
+	return s.repo.GetByLookup(tenantID, channel, name, locale, contentType)
 }
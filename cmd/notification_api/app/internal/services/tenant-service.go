@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"gorm.io/gorm"
+)
+
+type TenantService struct {
+	repo *repositories.TenantRepository
+}
+
+func NewTenantService(db *gorm.DB) *TenantService {
+	return &TenantService{repo: repositories.NewTenantRepository(db)}
+}
+
+func (s *TenantService) CreateTenant(tenant *models.Tenant) error {
+	if tenant.Name == "" {
+		return errors.New("tenant name is required")
+	}
+	if tenant.QuotaDaily < 0 || tenant.QuotaMonthly < 0 {
+		return errors.New("quotas cannot be negative")
+	}
+	if tenant.Plan == "" {
+		tenant.Plan = models.PlanStandard
+	}
+	if !models.ValidPlan(tenant.Plan) {
+		return errors.New("unknown plan " + tenant.Plan)
+	}
+	return s.repo.Create(tenant)
+}
+
+func (s *TenantService) GetTenant(id uuid.UUID) (*models.Tenant, error) {
+	if id == uuid.Nil {
+		return nil, errors.New("invalid tenant ID")
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *TenantService) ListTenants() ([]models.Tenant, error) {
+	return s.repo.List()
+}
+
+func (s *TenantService) UpdateTenant(tenant *models.Tenant) error {
+	if tenant.ID == uuid.Nil {
+		return errors.New("invalid tenant ID")
+	}
+	if tenant.Name == "" {
+		return errors.New("tenant name is required")
+	}
+	if tenant.QuotaDaily < 0 || tenant.QuotaMonthly < 0 {
+		return errors.New("quotas cannot be negative")
+	}
+	if !models.ValidPlan(tenant.Plan) {
+		return errors.New("unknown plan " + tenant.Plan)
+	}
+	return s.repo.Update(tenant)
+}
+
+func (s *TenantService) DeleteTenant(id uuid.UUID) error {
+	if id == uuid.Nil {
+		return errors.New("invalid tenant ID")
+	}
+	return s.repo.Delete(id)
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"gorm.io/gorm"
+)
+
+// maxUsageRange bounds how many days one usage query may cover.
+const maxUsageRange = 366
+
+type UsageService struct {
+	repo    *repositories.UsageRepository
+	tenants *repositories.TenantRepository
+}
+
+func NewUsageService(db *gorm.DB) *UsageService {
+	return &UsageService{
+		repo:    repositories.NewUsageRepository(db),
+		tenants: repositories.NewTenantRepository(db),
+	}
+}
+
+// QuotaError is returned when accepting more notifications would exceed one
+// of the tenant's quotas.
+type QuotaError = repositories.QuotaError
+
+// AcceptedCounts counts the items that Enqueue will accept, per channel.
+func AcceptedCounts(items []OutboundNotification) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, item := range items {
+		if item.Notification.Status == "" || Accepted(item.Notification.Status) {
+			counts[item.Notification.Channel]++
+		}
+	}
+	return counts
+}
+
+// CheckQuota returns a *QuotaError when accepting want more notifications
+// per channel would go over the tenant's monthly, per-channel monthly or
+// daily quota. The check runs before the notifications are stored, so
+// concurrent requests may overshoot a quota by their own size.
+func (s *UsageService) CheckQuota(tenantID uuid.UUID, want map[string]int64, now time.Time) error {
+	return s.repo.CheckQuota(tenantID, want, now)
+}
+
+func sum(counts map[string]int64) int64 {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
+
+// UsageTotals adds up a run of ledger rows.
+type UsageTotals struct {
+	Accepted  int64 `json:"accepted"`
+	Delivered int64 `json:"delivered"`
+	Failed    int64 `json:"failed"`
+}
+
+func (t *UsageTotals) add(day models.UsageDay) {
+	t.Accepted += day.Accepted
+	t.Delivered += day.Delivered
+	t.Failed += day.Failed
+}
+
+// UsageQuota is how much of a monthly quota the current month has used.
+type UsageQuota struct {
+	Channel string `json:"channel,omitempty"`
+	Monthly int64  `json:"monthly"`
+	Used    int64  `json:"used"`
+}
+
+type UsageReport struct {
+	From       string                 `json:"from"`
+	To         string                 `json:"to"`
+	Days       []models.UsageDay      `json:"days"`
+	Channels   map[string]UsageTotals `json:"channels"`
+	Totals     UsageTotals            `json:"totals"`
+	QuotaDaily int                    `json:"quota_daily"`
+	Quotas     []UsageQuota           `json:"quotas"`
+}
+
+// Report breaks the tenant's usage from from to to, both days inclusive,
+// down by day and channel, alongside the current month's quotas.
+func (s *UsageService) Report(tenantID uuid.UUID, from, to time.Time, channel string, now time.Time) (*UsageReport, error) {
+	from, to = models.UsageDate(from), models.UsageDate(to)
+	if to.Before(from) {
+		return nil, errors.New("to must not be before from")
+	}
+	if to.Sub(from) > maxUsageRange*24*time.Hour {
+		return nil, fmt.Errorf("a usage query may cover at most %d days", maxUsageRange)
+	}
+
+	tenant, err := s.tenants.GetByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	days, err := s.repo.Range(tenantID, from, to, channel)
+	if err != nil {
+		return nil, err
+	}
+	report := &UsageReport{
+		From:       from.Format(time.DateOnly),
+		To:         to.Format(time.DateOnly),
+		Days:       days,
+		Channels:   make(map[string]UsageTotals),
+		QuotaDaily: tenant.QuotaDaily,
+	}
+	for _, day := range days {
+		totals := report.Channels[day.Channel]
+		totals.add(day)
+		report.Channels[day.Channel] = totals
+		report.Totals.add(day)
+	}
+
+	month, err := s.repo.AcceptedSince(tenantID, models.MonthStart(now))
+	if err != nil {
+		return nil, err
+	}
+	report.Quotas = append(report.Quotas, UsageQuota{Monthly: int64(tenant.QuotaMonthly), Used: sum(month)})
+	quotas, err := s.repo.ListQuotas(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range quotas {
+		report.Quotas = append(report.Quotas, UsageQuota{Channel: q.Channel, Monthly: q.Monthly, Used: month[q.Channel]})
+	}
+	return report, nil
+}
+
+func (s *UsageService) ListQuotas(tenantID uuid.UUID) ([]models.ChannelQuota, error) {
+	if _, err := s.tenants.GetByID(tenantID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListQuotas(tenantID)
+}
+
+func (s *UsageService) SetQuota(quota *models.ChannelQuota) error {
+	if quota.Channel == "" {
+		return errors.New("channel is required")
+	}
+	if quota.Monthly <= 0 {
+		return errors.New("monthly quota must be positive")
+	}
+	if _, err := s.tenants.GetByID(quota.TenantID); err != nil {
+		return err
+	}
+	return s.repo.SetQuota(quota)
+}
+
+func (s *UsageService) DeleteQuota(tenantID uuid.UUID, channel string) error {
+	return s.repo.DeleteQuota(tenantID, channel)
+}
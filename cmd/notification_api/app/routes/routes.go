@@ -1,40 +1,176 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jsndz/signalbus/cmd/notification_api/app/internal/handler"
 	"github.com/jsndz/signalbus/middlewares"
 	"github.com/jsndz/signalbus/pkg/kafka"
+	"github.com/jsndz/signalbus/pkg/models"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
-func Notifications(router *gin.RouterGroup, p *kafka.Producer, db *gorm.DB, redisClient *redis.Client, log *zap.Logger, tracer trace.Tracer) {
-	notificationHandler := handler.NewNotificationHandler(db)
-	notifyMiddleware := middlewares.MiddlewareConfig{
+func Notifications(router *gin.RouterGroup, db *gorm.DB, redisClient *redis.Client, idempotencyTTL time.Duration, rateLimits middlewares.RateLimits, log *zap.Logger, tracer trace.Tracer) {
+	notificationHandler := handler.NewNotificationHandler(db, redisClient)
+	rateLimit := middlewares.RateLimitMiddleware(&middlewares.RateLimitConfig{
+		RedisClient: redisClient,
+		DB:          db,
+		Limits:      rateLimits,
+		Fallback:    middlewares.NewRateLimiter(10 * time.Minute),
+	})
+	idempotencyConfig := middlewares.IdempotencyConfig{
 		RedisClient: redisClient,
 		DB:          db,
+		TTL:         idempotencyTTL,
 	}
+	idempotency := middlewares.Idempotency(&idempotencyConfig)
+	router.Use(middlewares.APIKeyAuth(db))
+	send := middlewares.RequireScope(models.ScopeNotifySend)
+	read := middlewares.RequireScope(models.ScopeNotifyRead)
 
-	router.POST("/", middlewares.NotificationMiddleware(&notifyMiddleware), notificationHandler.Notify(p, db, log, tracer))
-	router.POST("/publish", middlewares.NotificationMiddleware(&notifyMiddleware), notificationHandler.Publish(p, db, log))
-	router.GET("/:id", notificationHandler.GetNotification(log))
-	router.POST("/:id/redrive", notificationHandler.RedriveNotification(log, p))
+	router.POST("/", send, rateLimit, idempotency, notificationHandler.Notify(log, tracer))
+	router.POST("/publish", send, rateLimit, idempotency, notificationHandler.Publish(log))
+	router.POST("/batch", send, rateLimit, notificationHandler.BatchNotify(middlewares.NewIdempotencyStore(&idempotencyConfig), log))
+	router.GET("/", read, notificationHandler.ListNotifications(log))
+	router.GET("/:id", read, notificationHandler.GetNotification(log))
+	router.GET("/:id/attempts", read, notificationHandler.ListAttempts(log))
+	router.GET("/:id/events", read, notificationHandler.ListEvents(log))
+	router.POST("/:id/cancel", send, notificationHandler.CancelNotification(log))
+	router.POST("/:id/redrive", send, notificationHandler.RedriveNotification(log))
 }
 
 func Templates(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
 	templateHandler := handler.NewTemplateHandler(db)
-	r.POST("/", templateHandler.CreateTemplate)
-	r.GET("/:id", templateHandler.GetTemplateByID)
-	r.PUT("/:id", templateHandler.UpdateTemplate)
-	r.DELETE("/:id", templateHandler.DeleteTemplate)
+	r.Use(middlewares.APIKeyAuth(db))
+	read := middlewares.RequireScope(models.ScopeTemplatesRead)
+	write := middlewares.RequireScope(models.ScopeTemplatesWrite)
+
+	r.POST("/", write, templateHandler.CreateTemplate)
+	r.GET("/:id", read, templateHandler.GetTemplateByID)
+	r.PUT("/:id", write, templateHandler.UpdateTemplate)
+	r.DELETE("/:id", write, templateHandler.DeleteTemplate)
 }
 
 func Policies(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
 	policyHandler := handler.NewPolicyHandler(db)
+	r.Use(middlewares.APIKeyAuth(db))
+	read := middlewares.RequireScope(models.ScopePoliciesRead)
+	write := middlewares.RequireScope(models.ScopePoliciesWrite)
+
+	r.POST("/", write, policyHandler.CreatePolicy)
+	r.GET("/", read, policyHandler.ListPolicies)
+	r.GET("/:id", read, policyHandler.GetPolicy)
+	r.PUT("/:id", write, policyHandler.UpdatePolicy)
+	r.DELETE("/:id", write, policyHandler.DeletePolicy)
+	r.GET("/:id/versions", read, policyHandler.ListVersions)
+	r.POST("/:id/rollback", write, policyHandler.RollbackPolicy)
+}
+
+func Schemas(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
+	schemaHandler := handler.NewEventSchemaHandler(db)
+	r.Use(middlewares.APIKeyAuth(db))
+	read := middlewares.RequireScope(models.ScopeSchemasRead)
+	write := middlewares.RequireScope(models.ScopeSchemasWrite)
+
+	r.POST("/", write, schemaHandler.CreateSchema)
+	r.GET("/", read, schemaHandler.ListSchemas)
+	r.GET("/:event_type", read, schemaHandler.GetSchema)
+	r.PUT("/:event_type", write, schemaHandler.UpdateSchema)
+	r.DELETE("/:event_type", write, schemaHandler.DeleteSchema)
+	r.POST("/:event_type/validate", read, schemaHandler.ValidatePayload)
+}
+
+func Preferences(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
+	preferenceHandler := handler.NewPreferenceHandler(db)
+	r.Use(middlewares.APIKeyAuth(db))
+	read := middlewares.RequireScope(models.ScopePreferencesRead)
+	write := middlewares.RequireScope(models.ScopePreferencesWrite)
+
+	r.POST("/", write, preferenceHandler.SetPreference)
+	r.GET("/", read, preferenceHandler.ListPreferences)
+	r.GET("/:id", read, preferenceHandler.GetPreference)
+	r.PUT("/:id", write, preferenceHandler.UpdatePreference)
+	r.DELETE("/:id", write, preferenceHandler.DeletePreference)
+}
+
+func QuietHours(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
+	quietHoursHandler := handler.NewQuietHoursHandler(db)
+	r.Use(middlewares.APIKeyAuth(db))
+	read := middlewares.RequireScope(models.ScopePreferencesRead)
+	write := middlewares.RequireScope(models.ScopePreferencesWrite)
+
+	r.PUT("/:user_ref", write, quietHoursHandler.SetQuietHours)
+	r.GET("/:user_ref", read, quietHoursHandler.GetQuietHours)
+	r.DELETE("/:user_ref", write, quietHoursHandler.DeleteQuietHours)
+}
+
+func Contacts(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
+	contactHandler := handler.NewContactHandler(db)
+	r.Use(middlewares.APIKeyAuth(db))
+	read := middlewares.RequireScope(models.ScopeContactsRead)
+	write := middlewares.RequireScope(models.ScopeContactsWrite)
+
+	r.PUT("/:user_ref", write, contactHandler.SetContact)
+	r.GET("/:user_ref", read, contactHandler.GetContact)
+	r.DELETE("/:user_ref", write, contactHandler.DeleteContact)
+}
+
+func Suppressions(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
+	suppressionHandler := handler.NewSuppressionHandler(db)
+	r.Use(middlewares.APIKeyAuth(db))
+	read := middlewares.RequireScope(models.ScopeSuppressionsRead)
+	write := middlewares.RequireScope(models.ScopeSuppressionsWrite)
+
+	r.POST("/", write, suppressionHandler.AddSuppression)
+	r.POST("/import", write, suppressionHandler.ImportSuppressions)
+	r.GET("/", read, suppressionHandler.ListSuppressions)
+	r.GET("/:id", read, suppressionHandler.GetSuppression)
+	r.DELETE("/:id", write, suppressionHandler.DeleteSuppression)
+}
+
+func Usage(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
+	usageHandler := handler.NewUsageHandler(db)
+	r.Use(middlewares.APIKeyAuth(db))
+
+	r.GET("/", middlewares.RequireScope(models.ScopeNotifyRead), usageHandler.GetUsage)
+}
+
+// Webhooks are called by providers, which sign their requests instead of
+// sending an API key.
+func Webhooks(r *gin.RouterGroup, db *gorm.DB, producer *kafka.Producer, log *zap.Logger) {
+	webhookHandler := handler.NewWebhookHandler(db, producer, log)
+
+	r.POST("/twilio/status", webhookHandler.TwilioStatus(log))
+	r.POST("/sms/inbound", webhookHandler.TwilioInbound(log))
+	r.POST("/sendgrid/events", webhookHandler.SendGridEvents(log))
+}
+
+func Tenants(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
+	tenantHandler := handler.NewTenantHandler(db)
+	usageHandler := handler.NewUsageHandler(db)
+	r.Use(middlewares.AdminAuth())
+
+	r.POST("/", tenantHandler.CreateTenant)
+	r.GET("/", tenantHandler.ListTenants)
+	r.GET("/:id", tenantHandler.GetTenant)
+	r.PUT("/:id", tenantHandler.UpdateTenant)
+	r.DELETE("/:id", tenantHandler.DeleteTenant)
+	r.GET("/:id/quotas", usageHandler.ListQuotas)
+	r.PUT("/:id/quotas/:channel", usageHandler.SetQuota)
+	r.DELETE("/:id/quotas/:channel", usageHandler.DeleteQuota)
+}
+
+func APIKeys(r *gin.RouterGroup, db *gorm.DB, log *zap.Logger) {
+	keyHandler := handler.NewAPIKeyHandler(db)
+	r.Use(middlewares.AdminAuth())
 
-	r.POST("/", policyHandler.CreatePolicy)
-	r.DELETE("/:id", policyHandler.DeletePolicy)
+	r.POST("/", keyHandler.CreateKey)
+	r.GET("/", keyHandler.ListKeys)
+	r.DELETE("/:id", keyHandler.RevokeKey)
+	r.POST("/:id/revoke", keyHandler.RevokeKey)
+	r.POST("/:id/rotate", keyHandler.RotateKey)
 }
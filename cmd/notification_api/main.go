@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -20,7 +22,11 @@ import (
 	"github.com/jsndz/signalbus/middlewares"
 	"github.com/jsndz/signalbus/pkg/database"
 	"github.com/jsndz/signalbus/pkg/kafka"
+	"github.com/jsndz/signalbus/pkg/digest"
+	"github.com/jsndz/signalbus/pkg/fallback"
 	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/outbox"
+	"github.com/jsndz/signalbus/pkg/scheduler"
 	"github.com/jsndz/signalbus/pkg/utils"
 	"github.com/jsndz/signalbus/tracing"
 )
@@ -47,9 +53,23 @@ func main() {
 	}
 	redis_dns := utils.GetEnv("REDIS_CLIENT")
 	redis := database.InitRedis(redis_dns)
+	database.MigrateDB(db, &models.Tenant{}, &models.APIKey{})
+	if err := database.BackfillTenants(db); err != nil {
+		panic("could not backfill tenants: " + err.Error())
+	}
+	if err := database.DedupePolicies(db); err != nil {
+		panic("could not dedupe policies: " + err.Error())
+	}
 	database.MigrateDB(db, &models.Template{})
-	database.MigrateDB(db, &models.Notification{}, &models.DeliveryAttempt{})
-	database.MigrateDB(db,  &models.Policy{},  &models.IdempotencyKey{})
+	database.MigrateDB(db, &models.Notification{}, &models.DeliveryAttempt{}, &models.DeliveryEvent{})
+	database.MigrateDB(db,  &models.Policy{}, &models.PolicyRule{}, &models.PolicyVersion{},  &models.IdempotencyKey{}, &models.IdempotencyLock{})
+	database.MigrateDB(db, &models.OutboxMessage{})
+	database.MigrateDB(db, &models.UserPreference{}, &models.QuietHours{})
+	database.MigrateDB(db, &models.Contact{}, &models.Suppression{})
+	database.MigrateDB(db, &models.InboundSMS{})
+	database.MigrateDB(db, &models.EventSchema{})
+	database.MigrateDB(db, &models.DigestItem{})
+	database.MigrateDB(db, &models.UsageDay{}, &models.ChannelQuota{})
 	if err != nil {
 		panic("DB not init  " + err.Error())
 	}
@@ -58,9 +78,20 @@ func main() {
 	metrics.InitKafkaMetrics()
 
 	metrics.InitAPIMetrics()
+	metrics.InitOutboxMetrics()
 	producer := kafka.NewProducer([]string{broker})
 	log.Info("Kafka producer initialized", zap.String("broker", broker))
 
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		outbox.NewRelay(db, producer, log, outbox.DefaultConfig()).Run(bgCtx)
+	}()
+	go scheduler.New(db, log, scheduler.DefaultConfig()).Run(bgCtx)
+	go fallback.New(db, log).Run(bgCtx)
+	go digest.New(db, log, digest.DefaultConfig()).Run(bgCtx)
+
 	router := gin.Default()
 	router.Use(middlewares.GinMetricsMiddleware())
 
@@ -76,24 +107,46 @@ func main() {
 	)))
 
 
+	idempotencyTTL := middlewares.IdempotencyTTLFromEnv(utils.GetEnv("IDEMPOTENCY_TTL"))
+	go middlewares.SweepIdempotencyKeys(bgCtx, db, idempotencyTTL, time.Hour, log)
+
 	v1 := router.Group("/api")
-	routes.Notifications(v1.Group("/notify"), producer, db, redis, log, tracer)
+	rateLimits, err := middlewares.RateLimitsFromEnv(utils.GetEnv("RATE_LIMITS"))
+	if err != nil {
+		log.Fatal("invalid rate limits", zap.Error(err))
+	}
+	routes.Notifications(v1.Group("/notify"), db, redis, idempotencyTTL, rateLimits, log, tracer)
 	routes.Policies(v1.Group("/policies"), db, log)
+	routes.Schemas(v1.Group("/schemas"), db, log)
+	routes.Preferences(v1.Group("/preferences"), db, log)
+	routes.QuietHours(v1.Group("/quiet-hours"), db, log)
+	routes.Contacts(v1.Group("/contacts"), db, log)
+	routes.Suppressions(v1.Group("/suppressions"), db, log)
+	routes.Usage(v1.Group("/usage"), db, log)
+	routes.Webhooks(v1.Group("/webhooks"), db, producer, log)
+	routes.Tenants(v1.Group("/tenants"), db, log)
+	routes.APIKeys(v1.Group("/keys"), db, log)
 
 	routes.Templates(v1.Group("/templates"), db, log)
-	go handleShutdown(producer, log)
+	go handleShutdown(producer, log, func() {
+		stopBackground()
+		<-relayDone
+	})
 	if err := router.Run(":3000"); err != nil {
 		log.Fatal("Failed to start server", zap.Error(err))
 	}
 }
 
-func handleShutdown(producer *kafka.Producer, log *zap.Logger) {
+func handleShutdown(producer *kafka.Producer, log *zap.Logger, stopRelay func()) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	sig := <-quit
 	log.Info("Shutdown signal received", zap.String("signal", sig.String()))
 
+	// Let the relay finish its in-flight batch before the producer goes away.
+	stopRelay()
+
 	if err := producer.Close(); err != nil {
 		log.Error("Error closing Kafka producer", zap.Error(err))
 	} else {
@@ -14,6 +14,7 @@ import (
 	"github.com/jsndz/signalbus/middlewares"
 	"github.com/jsndz/signalbus/pkg/config"
 	"github.com/jsndz/signalbus/pkg/database"
+	"github.com/jsndz/signalbus/pkg/gate"
 	"github.com/jsndz/signalbus/pkg/kafka"
 	"github.com/jsndz/signalbus/pkg/repositories"
 	"github.com/jsndz/signalbus/pkg/utils"
@@ -51,6 +52,8 @@ func main() {
 		panic("failed to initialize Database: " + err.Error())
 	}
 	notification_repo := repositories.NewNotificationRepository(notification_db)
+	suppression_repo := repositories.NewSuppressionRepository(notification_db)
+	delivery_gate := gate.New(notification_db)
 	logr.Info("Starting SMS worker")
 
 
@@ -61,7 +64,7 @@ func main() {
 	if err != nil {
 		logr.Fatal("failed to load config", zap.Error(err))
 	}
-	sender, err := config.BuildSender(cfg)
+	senders, err := config.BuildSenders(cfg)
 	if err != nil {
 		logr.Fatal("failed to init sender", zap.Error(err))
 	}
@@ -69,7 +72,7 @@ func main() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go service.HandleSMS(broker, ctx, sender, logr, tmplRepo,notification_repo,producer,cfg.SMS.Provider,tracer)
+	go service.HandleSMS(broker, ctx, senders, logr, tmplRepo,notification_repo,suppression_repo,delivery_gate,producer,cfg.SMS.Provider,tracer)
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -3,14 +3,18 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/metrics"
+	"github.com/jsndz/signalbus/pkg/fallback"
+	"github.com/jsndz/signalbus/pkg/gate"
 	"github.com/jsndz/signalbus/pkg/gosms"
 	"github.com/jsndz/signalbus/pkg/kafka"
+	"github.com/jsndz/signalbus/pkg/lanes"
 	"github.com/jsndz/signalbus/pkg/models"
 	"github.com/jsndz/signalbus/pkg/repositories"
 	"github.com/jsndz/signalbus/pkg/templates"
@@ -28,19 +32,22 @@ const maxRetries = 3
 
 func HandleSMS(
     broker string, ctx context.Context,
-    smsService gosms.Sender,
+    senders map[string]gosms.Sender,
     logger *zap.Logger,
     tmplRepo *repositories.TemplateRepository,
     notificationRepo *repositories.NotificationRepository,
+    suppressionRepo *repositories.SuppressionRepository,
+    deliveryGate *gate.Gate,
     producer *kafka.Producer,
     provider string,
     tracer trace.Tracer,
 ) {
     topic := "notification.sms"
-    c := kafka.NewConsumerFromEnv(topic, "sms")
+    // every priority lane, high first by weight
+    c := lanes.NewReader(ctx, "sms", "sms", lanes.DefaultWeights)
     defer c.Close()
     
-    logger.Info("Starting Kafka consumer", zap.String("topic", topic), zap.String("broker", broker))
+    logger.Info("Starting Kafka consumer", zap.String("topic", topic), zap.Strings("lanes", lanes.All), zap.String("broker", broker))
     
     for {
         select {
@@ -48,7 +55,7 @@ func HandleSMS(
             logger.Info("Shutting down SMS Consumer", zap.String("topic", topic))
             return
         default:
-            m, err := c.ReadFromKafka(ctx)
+            m, err := c.Read(ctx)
             if err != nil {
                 logger.Error("Error reading Kafka message", zap.String("topic", topic), zap.Error(err))
                 continue
@@ -74,10 +81,42 @@ func HandleSMS(
                     )
                     return
                 }
+                decision, err := deliveryGate.Check(msg, time.Now())
+                if err != nil {
+                    logger.Warn("Delivery gate check failed, sending anyway",
+                        zap.String("notification_id", msg.NotificationId.String()),
+                        zap.Error(err),
+                    )
+                }
+                if decision.Action == gate.Defer {
+                    err := deliveryGate.Hold(smsCtx, msg, m.Key, decision)
+                    if errors.Is(err, gate.ErrFinal) {
+                        // a redelivery of a message that was handled already
+                        logger.Info("Notification is final, skipping SMS",
+                            zap.String("notification_id", msg.NotificationId.String()),
+                        )
+                        return
+                    }
+                    if err != nil {
+                        logger.Error("Failed to defer SMS",
+                            zap.String("notification_id", msg.NotificationId.String()),
+                            zap.Error(err),
+                        )
+                        return
+                    }
+                    logger.Info("SMS deferred",
+                        zap.String("notification_id", msg.NotificationId.String()),
+                        zap.String("reason", decision.Reason),
+                        zap.Time("until", decision.Until),
+                    )
+                    return
+                }
+
                 _, tmplspan := tracer.Start(smsCtx, "template extraction")
                 defer tmplspan.End()
                 logger.Info("Kafka message received",
                     zap.String("topic", topic),
+                    zap.String("lane", m.Lane),
                     zap.ByteString("key", m.Key),
                     zap.Int64("offset", m.Offset),
                 )
@@ -87,6 +126,7 @@ func HandleSMS(
                 if msg.GetTemplateData != nil {
                     content, err := templates.Render(
                         msg.InTemplateData,
+                        msg.TenantID,
                         "sms",  
                         msg.GetTemplateData.EventType,
                         msg.GetTemplateData.Locale,
@@ -134,14 +174,54 @@ func HandleSMS(
                     textContent, 
                     gosms.WithIdempotencyKey(msg.IdempotencyKey), 
                 )
-                
+
+                // a policy rule may have picked another configured provider
+                smsService, sendProvider := senders[provider], provider
+                if s, ok := senders[msg.Provider]; ok {
+                    smsService, sendProvider = s, msg.Provider
+                } else if msg.Provider != "" {
+                    logger.Warn("Provider override is not configured, using default",
+                        zap.String("provider", msg.Provider),
+                        zap.String("notification_id", msg.NotificationId.String()),
+                    )
+                }
+
+                // the number may have replied STOP since the notification
+                // was accepted
+                if to, hit, err := suppressionRepo.Filter(msg.TenantID, "sms", []string{sms.To}, time.Now()); err != nil {
+                    logger.Warn("Suppression check failed, sending anyway",
+                        zap.String("notification_id", msg.NotificationId.String()),
+                        zap.Error(err),
+                    )
+                } else if len(to) == 0 && hit != nil {
+                    reason := "recipient suppressed: " + hit.Reason
+                    notificationRepo.MarkSuppressed(msg.TenantID, msg.NotificationId, reason)
+                    notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+                        TenantID:       msg.TenantID,
+                        NotificationID: msg.NotificationId,
+                        Channel:        "sms",
+                        Provider:       sendProvider,
+                        Status:         "suppressed",
+                        Error:          reason,
+                        Try:            1,
+                    })
+                    metrics.NotificationsAttemptedTotal.WithLabelValues("sms", "suppressed", sendProvider).Inc()
+                    logger.Info("Recipient suppressed, skipping send",
+                        zap.String("notification_id", msg.NotificationId.String()),
+                        zap.String("reason", hit.Reason),
+                    )
+                    return
+                }
+
                 SendSMSWithRetry(smsCtx,
                     logger, 
                     smsService, 
                     sms, 
+                    msg,
                     producer, 
+                    msg.TenantID,
                     msg.NotificationId, 
-                    notificationRepo,provider,
+                    notificationRepo,sendProvider,
                     tracer ,
                 )
             }()
@@ -152,7 +232,9 @@ func SendSMSWithRetry(ctx context.Context,
     logger *zap.Logger,
     smsService gosms.Sender,
     sms gosms.SMS,
+    msg types.KafkaStreamData,
     producer *kafka.Producer,
+    tenantID uuid.UUID,
     notificationID uuid.UUID,
     notificationRepo *repositories.NotificationRepository,
     provider string,
@@ -163,32 +245,70 @@ func SendSMSWithRetry(ctx context.Context,
     _, sendSpan := tracer.Start(ctx, "send-sms")
 	defer sendSpan.End()
     for attempt := 1; attempt <= maxRetries; attempt++ {
+        // the notification may have been recalled since it was queued or
+        // while this worker was backing off
+        if cancelled, err := notificationRepo.Cancelled(tenantID, notificationID); err != nil {
+            logger.Warn("Couldn't check whether the notification was cancelled",
+                zap.String("notification_id", notificationID.String()),
+                zap.Error(err),
+            )
+        } else if cancelled {
+            notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+                TenantID:       tenantID,
+                NotificationID: notificationID,
+                Channel:        "sms",
+                Provider:       provider,
+                Status:         "cancelled",
+                Try:            attempt,
+            })
+            metrics.NotificationsAttemptedTotal.WithLabelValues("sms", "cancelled", provider).Inc()
+            sendSpan.AddEvent("notification cancelled")
+            logger.Info("Notification cancelled, skipping send",
+                zap.String("notification_id", notificationID.String()),
+                zap.Int("attempt", attempt),
+            )
+            return nil
+        }
+
         start := time.Now()
         apiTimer := prometheus.NewTimer(metrics.ExternalAPIDuration.WithLabelValues(provider, "sms"))
         metrics.ExternalAPISuccessTotal.WithLabelValues(provider, "sms_worker").Inc()
 
-        _,err := smsService.Send(sms)
+        resp, err := smsService.Send(sms)
         apiTimer.ObserveDuration()
         latency := time.Since(start).Milliseconds()
 
         if err == nil {
-            notificationRepo.UpdateStatus(notificationID, "delivered")
+            // with a status callback the carrier's receipt settles the outcome
+            status, attemptStatus := "delivered", "delivered"
+            var providerMessageID string
+            if resp != nil {
+                providerMessageID = resp.ProviderID
+                if resp.Status == types.StatusAwaitingReceipt {
+                    status, attemptStatus = "sent", types.AttemptAccepted
+                }
+            }
+            notificationRepo.UpdateStatus(tenantID, notificationID, status)
             notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+                TenantID:       tenantID,
                 NotificationID: notificationID,
                 Channel:        "sms",
                 Provider:       provider,
-                Status:         "delivered",
+                Status:         attemptStatus,
                 Try:            attempt,
                 LatencyMs:      latency,
+                ProviderMessageID: providerMessageID,
             })
             metrics.NotificationsAttemptedTotal.WithLabelValues("sms", "success", provider).Inc()
             return nil
         }
         sendSpan.AddEvent(fmt.Sprintf("Retry %d failed", attempt))
 		sendSpan.RecordError(err)
-        metrics.NotificationRetriesTotal.WithLabelValues("sms", provider, "provider_error").Inc()
+        metrics.NotificationRetriesTotal.WithLabelValues("provider_error", "sms").Inc()
         metrics.NotificationsAttemptedTotal.WithLabelValues("sms", "failed", provider).Inc()
+        notificationRepo.MarkRetrying(tenantID, notificationID)
         notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+            TenantID:       tenantID,
             NotificationID: notificationID,
             Channel:        "sms",
             Provider:       provider,
@@ -212,7 +332,28 @@ func SendSMSWithRetry(ctx context.Context,
     }
 
     metrics.ExternalAPIFailureTotal.WithLabelValues(provider, "sms_worker").Inc()
-    notificationRepo.UpdateStatus(notificationID, "failed")
+    // a cancel that landed during the last backoff wins over the failure
+    if failed, err := notificationRepo.MarkFailed(tenantID, notificationID); err != nil {
+        logger.Warn("Couldn't mark the notification failed",
+            zap.String("notification_id", notificationID.String()),
+            zap.Error(err),
+        )
+    } else if !failed {
+        notificationRepo.CreateAttempt(&models.DeliveryAttempt{
+            TenantID:       tenantID,
+            NotificationID: notificationID,
+            Channel:        "sms",
+            Provider:       provider,
+            Status:         "cancelled",
+            Try:            maxRetries,
+        })
+        metrics.NotificationsAttemptedTotal.WithLabelValues("sms", "cancelled", provider).Inc()
+        sendSpan.AddEvent("notification cancelled")
+        logger.Info("Notification cancelled, skipping DLQ and fallback",
+            zap.String("notification_id", notificationID.String()),
+        )
+        return nil
+    }
 
     smsBytes, marshalErr := json.Marshal(sms)
     _, dlqSpan := tracer.Start(ctx, "publish-dlq")
@@ -235,7 +376,26 @@ func SendSMSWithRetry(ctx context.Context,
         dlqSpan.SetStatus(codes.Ok, "dlq published")
     }
 
-	metrics.NotificationDLQTotal.WithLabelValues("provider_error","sms")
+	metrics.NotificationDLQTotal.WithLabelValues("provider_error","sms").Inc()
+    // redrive republishes the message the worker consumed
+    if attempt, err := repositories.NewDLQAttempt(msg, "sms", provider); err != nil {
+        logger.Error("Failed to marshal message for DLQ attempt",
+            zap.String("notification_id", notificationID.String()),
+            zap.Error(err),
+        )
+    } else if err := notificationRepo.CreateAttempt(attempt); err != nil {
+        logger.Error("Failed to record DLQ attempt",
+            zap.String("notification_id", notificationID.String()),
+            zap.Error(err),
+        )
+    }
+
+    if err := fallback.Publish(ctx, producer, tenantID, notificationID, "sms", "provider_error"); err != nil {
+        logger.Error("Failed to publish fallback event",
+            zap.String("notification_id", notificationID.String()),
+            zap.Error(err),
+        )
+    }
 
     logger.Error("Final SMS send failure",
         zap.String("to", sms.To),
@@ -94,6 +94,45 @@ var KafkaConsumerLag = prometheus.NewGaugeVec(
 	[]string{"group", "topic",},
 )
 
+var NotificationLaneLag = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "notification_lane_lag",
+		Help: "Messages a channel worker is behind on each priority lane",
+	},
+	[]string{"channel", "lane"},
+)
+
+var HttpRateLimitFallbackTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "http_rate_limit_fallback_total",
+		Help: "Requests rate limited in-process because Redis was unavailable",
+	},
+)
+
+var DeliveryReceiptsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "delivery_receipts_total",
+		Help: "Delivery reports received from providers",
+	},
+	[]string{"provider", "status"},
+)
+
+var InboundSMSTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "inbound_sms_total",
+		Help: "Texts received from recipients, by compliance keyword",
+	},
+	[]string{"provider", "keyword"},
+)
+
+var TenantUsageTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tenant_usage_total",
+		Help: "Notifications accepted per tenant and channel",
+	},
+	[]string{"tenant", "channel"},
+)
+
 var KafkaRebalancesTotal = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
 		Name: "kafka_rebalances_total",
@@ -127,11 +166,30 @@ var ExternalAPIDuration = prometheus.NewHistogramVec(
 	[]string{"provider", "service"},
 )
 
+var OutboxPublishedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "outbox_published_total",
+		Help: "Outbox messages handed to Kafka, by outcome (sent, retry, failed)",
+	},
+	[]string{"topic", "status"},
+)
+
+var OutboxPending = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "outbox_pending",
+		Help: "Outbox messages waiting to be published",
+	},
+)
+
 func InitAPIMetrics() {
 	prometheus.MustRegister(HttpRequestsTotal)
 	prometheus.MustRegister(HttpRequestDuration)
 	prometheus.MustRegister(HttpErrorsTotal)
 	prometheus.MustRegister(HttpRateLimitRejectionsTotal)
+	prometheus.MustRegister(HttpRateLimitFallbackTotal)
+	prometheus.MustRegister(TenantUsageTotal)
+	prometheus.MustRegister(DeliveryReceiptsTotal)
+	prometheus.MustRegister(InboundSMSTotal)
 }
 
 func InitWorkerMetrics() {
@@ -144,10 +202,16 @@ func InitWorkerMetrics() {
 	prometheus.MustRegister(ExternalAPIDuration)
 }
 
+func InitOutboxMetrics() {
+	prometheus.MustRegister(OutboxPublishedTotal)
+	prometheus.MustRegister(OutboxPending)
+}
+
 func InitKafkaMetrics() {
 	prometheus.MustRegister(KafkaPublishFailureTotal)
 	prometheus.MustRegister(KafkaSubscriberFailureTotal)
 	prometheus.MustRegister(KafkaConsumerLag)
+	prometheus.MustRegister(NotificationLaneLag)
 	prometheus.MustRegister(KafkaRebalancesTotal)
 }
 
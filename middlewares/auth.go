@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/jsndz/signalbus/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const apiKeyContextKey = "api_key"
+
+// lastUsedResolution bounds how often last_used_at is written for a busy key.
+const lastUsedResolution = time.Minute
+
+// APIKeyAuth authenticates the X-API-Key header and stores the key's tenant
+// and scopes on the context for GetTenantID and RequireScope.
+func APIKeyAuth(db *gorm.DB) gin.HandlerFunc {
+	repo := repositories.NewAPIKeyRepository(db)
+	return func(ctx *gin.Context) {
+		raw := ctx.GetHeader("X-API-Key")
+		if raw == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing X-API-Key header"})
+			return
+		}
+		now := time.Now()
+		key, err := repo.GetActiveByHash(utils.HashAPIKey(raw), now)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return
+		}
+		if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedResolution {
+			_ = repo.TouchLastUsed(key.ID, now)
+		}
+
+		SetTenantID(ctx, key.TenantID)
+		ctx.Set(apiKeyContextKey, key)
+		ctx.Next()
+	}
+}
+
+// RequireScope rejects requests whose API key was not granted scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := GetAPIKey(ctx)
+		if key == nil || !key.HasScope(scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key lacks scope " + scope})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// GetAPIKey returns the key authenticated by APIKeyAuth, or nil.
+func GetAPIKey(ctx *gin.Context) *models.APIKey {
+	if v, ok := ctx.Get(apiKeyContextKey); ok {
+		if key, ok := v.(*models.APIKey); ok {
+			return key
+		}
+	}
+	return nil
+}
+
+// AdminAuth guards tenant and key management with the shared token in
+// SIGNALBUS_ADMIN_TOKEN. When the variable is unset every request is refused.
+func AdminAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token := utils.GetEnv("SIGNALBUS_ADMIN_TOKEN")
+		given := ctx.GetHeader("X-Admin-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(given)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,368 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	IdempotencyHeader = "X-Idempotency-Key"
+
+	// DefaultIdempotencyTTL is how long a stored response is replayed.
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultIdempotencyLockTTL bounds how long a crashed request can hold
+	// its key before another attempt may proceed.
+	DefaultIdempotencyLockTTL = 30 * time.Second
+
+	maxIdempotencyKeyLen = 64
+)
+
+// releaseLock deletes the in-progress marker only if this request still
+// owns it, so a request that outlived its lock cannot free someone else's.
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type IdempotencyConfig struct {
+	RedisClient *redis.Client
+	DB          *gorm.DB
+	// TTL is how long a completed response is replayed for the same key.
+	TTL time.Duration
+	// LockTTL is how long a request may hold its key while in progress.
+	LockTTL time.Duration
+}
+
+// IdempotencyTTLFromEnv reads IDEMPOTENCY_TTL as a Go duration ("24h",
+// "90m"), falling back to DefaultIdempotencyTTL.
+func IdempotencyTTLFromEnv(value string) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	return DefaultIdempotencyTTL
+}
+
+var (
+	// ErrIdempotencyConflict means the key was already used for a request
+	// with a different body.
+	ErrIdempotencyConflict = errors.New("idempotency key was already used with a different request body")
+	// ErrIdempotencyInProgress means another request holds the key.
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is already in progress")
+)
+
+// StoredResponse is a response recorded under an idempotency key.
+type StoredResponse struct {
+	RequestHash string `json:"h"`
+	StatusCode  int    `json:"s"`
+	Body        string `json:"b"`
+}
+
+// idempotencyRecords is the Postgres side of the store, a
+// *repositories.IdempotencyRepository.
+type idempotencyRecords interface {
+	Get(tenantID uuid.UUID, key string, notBefore time.Time) (*models.IdempotencyKey, error)
+	Save(record *models.IdempotencyKey) error
+	Reserve(tenantID uuid.UUID, key, token string, now, expiresAt time.Time) (bool, error)
+	Release(tenantID uuid.UUID, key, token string) error
+}
+
+// IdempotencyStore keeps responses in Redis in front of Postgres and
+// reserves keys while their request runs. The Idempotency middleware uses it
+// per request; the batch endpoint uses it per item.
+type IdempotencyStore struct {
+	rdb     *redis.Client
+	repo    idempotencyRecords
+	ttl     time.Duration
+	lockTTL time.Duration
+}
+
+func NewIdempotencyStore(cfg *IdempotencyConfig) *IdempotencyStore {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	lockTTL := cfg.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = DefaultIdempotencyLockTTL
+	}
+	return &IdempotencyStore{
+		rdb:     cfg.RedisClient,
+		repo:    repositories.NewIdempotencyRepository(cfg.DB),
+		ttl:     ttl,
+		lockTTL: lockTTL,
+	}
+}
+
+// ValidateIdempotencyKey checks a client supplied key.
+func ValidateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLen {
+		return fmt.Errorf("idempotency key must be at most %d characters", maxIdempotencyKeyLen)
+	}
+	return nil
+}
+
+// Lookup returns the stored response for key, or nil if there is none. It
+// returns ErrIdempotencyConflict when the stored request hash differs.
+func (s *IdempotencyStore) Lookup(ctx context.Context, tenantID uuid.UUID, key, hash string) (*StoredResponse, error) {
+	cacheKey := idempotencyCacheKey(tenantID, key)
+	stored, ok := s.lookupCached(ctx, cacheKey)
+	if !ok {
+		record, err := s.repo.Get(tenantID, key, time.Now().Add(-s.ttl))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		stored = StoredResponse{
+			RequestHash: record.RequestHash,
+			StatusCode:  record.StatusCode,
+			Body:        record.Response,
+		}
+		if remaining := time.Until(record.CreatedAt.Add(s.ttl)); remaining > 0 {
+			s.cache(ctx, cacheKey, stored, remaining)
+		}
+	}
+	if stored.RequestHash != hash {
+		return nil, ErrIdempotencyConflict
+	}
+	return &stored, nil
+}
+
+// Reserve marks key as in progress until release is called or the lock TTL
+// passes. It returns ErrIdempotencyInProgress if another request holds it.
+// When Redis is unavailable the key is reserved in Postgres instead, so
+// duplicates are still turned away; any other error means the key could
+// not be reserved at all.
+func (s *IdempotencyStore) Reserve(ctx context.Context, tenantID uuid.UUID, key string) (release func(), err error) {
+	token := uuid.NewString()
+	lockKey := idempotencyCacheKey(tenantID, key) + ":lock"
+	acquired, err := s.rdb.SetNX(ctx, lockKey, token, s.lockTTL).Result()
+	if err != nil {
+		return s.reserveDurable(tenantID, key, token)
+	}
+	if !acquired {
+		return nil, ErrIdempotencyInProgress
+	}
+	return func() {
+		releaseLock.Run(context.Background(), s.rdb, []string{lockKey}, token)
+	}, nil
+}
+
+func (s *IdempotencyStore) reserveDurable(tenantID uuid.UUID, key, token string) (func(), error) {
+	now := time.Now()
+	acquired, err := s.repo.Reserve(tenantID, key, token, now, now.Add(s.lockTTL))
+	if err != nil {
+		return nil, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+	if !acquired {
+		return nil, ErrIdempotencyInProgress
+	}
+	return func() {
+		s.repo.Release(tenantID, key, token)
+	}, nil
+}
+
+// Save records a completed response for key.
+func (s *IdempotencyStore) Save(ctx context.Context, tenantID uuid.UUID, key string, resp StoredResponse) error {
+	err := s.repo.Save(&models.IdempotencyKey{
+		TenantID:    tenantID,
+		Key:         key,
+		RequestHash: resp.RequestHash,
+		Response:    resp.Body,
+		StatusCode:  resp.StatusCode,
+	})
+	s.cache(ctx, idempotencyCacheKey(tenantID, key), resp, s.ttl)
+	return err
+}
+
+func (s *IdempotencyStore) lookupCached(ctx context.Context, cacheKey string) (StoredResponse, bool) {
+	var stored StoredResponse
+	raw, err := s.rdb.Get(ctx, cacheKey).Bytes()
+	if err != nil {
+		return stored, false
+	}
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return stored, false
+	}
+	return stored, true
+}
+
+func (s *IdempotencyStore) cache(ctx context.Context, cacheKey string, resp StoredResponse, ttl time.Duration) {
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	s.rdb.Set(ctx, cacheKey, raw, ttl)
+}
+
+func idempotencyCacheKey(tenantID uuid.UUID, key string) string {
+	return fmt.Sprintf("idem:%s:%s", tenantID, key)
+}
+
+// Idempotency makes a route safe to retry with the same X-Idempotency-Key.
+// Requests without the header pass straight through. A completed 2xx
+// response is replayed for the TTL; reusing the key with a different body is
+// rejected with 422, and a duplicate that arrives while the first request is
+// still running gets 409. Must run after APIKeyAuth, as keys are per tenant.
+func Idempotency(cfg *IdempotencyConfig) gin.HandlerFunc {
+	return idempotency(NewIdempotencyStore(cfg))
+}
+
+func idempotency(store *IdempotencyStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := strings.TrimSpace(ctx.GetHeader(IdempotencyHeader))
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if err := ValidateIdempotencyKey(key); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "could not read request body"})
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		tenantID := GetTenantID(ctx)
+		hash := RequestHash(ctx.Request.Method, ctx.FullPath(), body)
+
+		if replayStored(ctx, store, tenantID, key, hash) {
+			return
+		}
+
+		release, err := store.Reserve(ctx, tenantID, key)
+		if errors.Is(err, ErrIdempotencyInProgress) {
+			ctx.Header("Retry-After", strconv.Itoa(int(store.lockTTL.Seconds())))
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not reserve idempotency key"})
+			return
+		}
+		defer release()
+
+		// The first request may have finished between the lookup above and
+		// taking the lock.
+		if replayStored(ctx, store, tenantID, key, hash) {
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		ctx.Next()
+
+		status := writer.Status()
+		if status < 200 || status >= 300 {
+			return
+		}
+		if err := store.Save(ctx, tenantID, key, StoredResponse{
+			RequestHash: hash,
+			StatusCode:  status,
+			Body:        writer.body.String(),
+		}); err != nil {
+			ctx.Error(fmt.Errorf("store idempotency record: %w", err))
+		}
+	}
+}
+
+// replayStored writes a stored response for key and reports whether the
+// request was answered, either by the replay or by a 422 conflict.
+func replayStored(ctx *gin.Context, store *IdempotencyStore, tenantID uuid.UUID, key, hash string) bool {
+	stored, err := store.Lookup(ctx, tenantID, key, hash)
+	if errors.Is(err, ErrIdempotencyConflict) {
+		ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return true
+	}
+	if err != nil || stored == nil {
+		return false
+	}
+	ctx.Header("Idempotent-Replayed", "true")
+	ctx.Data(stored.StatusCode, "application/json", []byte(stored.Body))
+	ctx.Abort()
+	return true
+}
+
+// RequestHash fingerprints a request. JSON bodies are re-encoded first so
+// whitespace and key order do not make a retry look like a different
+// request.
+func RequestHash(method, route string, body []byte) string {
+	canonical := body
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if b, err := json.Marshal(v); err == nil {
+			canonical = b
+		}
+	}
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(route))
+	h.Write([]byte{0})
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// SweepIdempotencyKeys deletes expired idempotency records every interval
+// until ctx is cancelled.
+func SweepIdempotencyKeys(ctx context.Context, db *gorm.DB, ttl, interval time.Duration, log *zap.Logger) {
+	repo := repositories.NewIdempotencyRepository(db)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := repo.DeleteExpired(time.Now().Add(-ttl))
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Error("failed to sweep idempotency keys", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				log.Info("swept expired idempotency keys", zap.Int64("deleted", n))
+			}
+		}
+	}
+}
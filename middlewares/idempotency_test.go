@@ -0,0 +1,161 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+func TestRequestHashIgnoresJSONFormatting(t *testing.T) {
+	a := RequestHash("POST", "/api/notify/", []byte(`{"event_type":"signup","data":{"to":"a@b.c"}}`))
+	b := RequestHash("POST", "/api/notify/", []byte("{\n  \"data\": {\"to\": \"a@b.c\"},\n  \"event_type\": \"signup\"\n}"))
+	if a != b {
+		t.Fatalf("expected equivalent JSON bodies to hash the same")
+	}
+}
+
+func TestRequestHashDistinguishesBodyAndRoute(t *testing.T) {
+	body := []byte(`{"event_type":"signup"}`)
+	base := RequestHash("POST", "/api/notify/", body)
+
+	if RequestHash("POST", "/api/notify/", []byte(`{"event_type":"reset"}`)) == base {
+		t.Fatalf("expected a different body to change the hash")
+	}
+	if RequestHash("POST", "/api/notify/publish", body) == base {
+		t.Fatalf("expected a different route to change the hash")
+	}
+}
+
+// memRecords is an in-memory idempotencyRecords.
+type memRecords struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyKey
+	locks   map[string]models.IdempotencyLock
+}
+
+func newMemRecords() *memRecords {
+	return &memRecords{
+		records: make(map[string]models.IdempotencyKey),
+		locks:   make(map[string]models.IdempotencyLock),
+	}
+}
+
+func (m *memRecords) Get(tenantID uuid.UUID, key string, notBefore time.Time) (*models.IdempotencyKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[tenantID.String()+key]
+	if !ok || !record.CreatedAt.After(notBefore) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &record, nil
+}
+
+func (m *memRecords) Save(record *models.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record.CreatedAt = time.Now()
+	m.records[record.TenantID.String()+record.Key] = *record
+	return nil
+}
+
+func (m *memRecords) Reserve(tenantID uuid.UUID, key, token string, now, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if lock, ok := m.locks[tenantID.String()+key]; ok && lock.ExpiresAt.After(now) {
+		return false, nil
+	}
+	m.locks[tenantID.String()+key] = models.IdempotencyLock{TenantID: tenantID, Key: key, Token: token, ExpiresAt: expiresAt}
+	return true, nil
+}
+
+func (m *memRecords) Release(tenantID uuid.UUID, key, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks[tenantID.String()+key].Token == token {
+		delete(m.locks, tenantID.String()+key)
+	}
+	return nil
+}
+
+// storeWithoutRedis is a store whose Redis is unreachable, so every
+// reservation falls back to Postgres.
+func storeWithoutRedis(t *testing.T) *IdempotencyStore {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { rdb.Close() })
+	return &IdempotencyStore{rdb: rdb, repo: newMemRecords(), ttl: time.Hour, lockTTL: time.Minute}
+}
+
+func TestIdempotencyReserveWithoutRedis(t *testing.T) {
+	store := storeWithoutRedis(t)
+	tenantID := uuid.New()
+
+	release, err := store.Reserve(context.Background(), tenantID, "k1")
+	if err != nil {
+		t.Fatalf("first reservation: %v", err)
+	}
+	if _, err := store.Reserve(context.Background(), tenantID, "k1"); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Fatalf("second reservation: got %v, want ErrIdempotencyInProgress", err)
+	}
+	release()
+	if _, err := store.Reserve(context.Background(), tenantID, "k1"); err != nil {
+		t.Fatalf("reservation after release: %v", err)
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storeWithoutRedis(t)
+	tenantID := uuid.New()
+	calls := 0
+
+	r := gin.New()
+	r.POST("/notify", func(c *gin.Context) {
+		SetTenantID(c, tenantID)
+	}, idempotency(store), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusAccepted, gin.H{"call": calls})
+	})
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body))
+		req.Header.Set(IdempotencyHeader, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("k1", `{"event_type":"signup"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("first request: got %d", w.Code)
+	}
+	w := send("k1", `{"event_type":"signup"}`)
+	if w.Code != http.StatusAccepted || w.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+		t.Errorf("retry should replay: got %d, replayed %q, %d calls", w.Code, w.Header().Get("Idempotent-Replayed"), calls)
+	}
+	if w := send("k1", `{"event_type":"reset"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body: got %d, want 422", w.Code)
+	}
+
+	// a duplicate arriving while the first request still holds its key
+	release, err := store.Reserve(context.Background(), tenantID, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	w = send("k2", `{"event_type":"signup"}`)
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("key in progress: got %d, Retry-After %q, want 409", w.Code, w.Header().Get("Retry-After"))
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,263 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/metrics"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// AnyRoute and AnyPlan are the fallbacks in RateLimits.
+const (
+	AnyRoute = "*"
+	AnyPlan  = "*"
+)
+
+// RateLimit allows Requests per sliding Window.
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseRateLimit reads "100/1m": requests per Go duration.
+func ParseRateLimit(s string) (RateLimit, error) {
+	n, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q: want requests/window, e.g. 100/1m", s)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil || requests <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q: requests must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q: window must be a positive duration", s)
+	}
+	return RateLimit{Requests: requests, Window: d}, nil
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
+
+// RateLimits holds a limit per route (gin's full path, e.g.
+// /api/notify/batch) and tenant plan. AnyRoute and AnyPlan stand in for
+// routes and plans without an entry of their own.
+type RateLimits map[string]map[string]RateLimit
+
+// DefaultRateLimits apply unless RATE_LIMITS overrides them. Batches count
+// as one request each but carry up to 100 notifications, so they get less.
+var DefaultRateLimits = RateLimits{
+	AnyRoute: {
+		models.PlanFree:       {Requests: 60, Window: time.Minute},
+		models.PlanStandard:   {Requests: 600, Window: time.Minute},
+		models.PlanEnterprise: {Requests: 3000, Window: time.Minute},
+		AnyPlan:               {Requests: 600, Window: time.Minute},
+	},
+	"/api/notify/batch": {
+		models.PlanFree:       {Requests: 6, Window: time.Minute},
+		models.PlanStandard:   {Requests: 60, Window: time.Minute},
+		models.PlanEnterprise: {Requests: 300, Window: time.Minute},
+		AnyPlan:               {Requests: 60, Window: time.Minute},
+	},
+}
+
+// Lookup returns the limit for route and plan, preferring an exact route,
+// then an exact plan.
+func (l RateLimits) Lookup(route, plan string) (RateLimit, bool) {
+	for _, r := range []string{route, AnyRoute} {
+		plans, ok := l[r]
+		if !ok {
+			continue
+		}
+		if limit, ok := plans[plan]; ok {
+			return limit, true
+		}
+		if limit, ok := plans[AnyPlan]; ok {
+			return limit, true
+		}
+	}
+	return RateLimit{}, false
+}
+
+// RateLimitsFromEnv reads RATE_LIMITS, a JSON object of route to plan to
+// limit, e.g. {"/api/notify/": {"free": "10/1m"}}, and lays it over
+// DefaultRateLimits.
+func RateLimitsFromEnv(value string) (RateLimits, error) {
+	limits := make(RateLimits, len(DefaultRateLimits))
+	for route, plans := range DefaultRateLimits {
+		limits[route] = make(map[string]RateLimit, len(plans))
+		for plan, limit := range plans {
+			limits[route][plan] = limit
+		}
+	}
+	if strings.TrimSpace(value) == "" {
+		return limits, nil
+	}
+
+	var raw map[string]map[string]string
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("RATE_LIMITS: %w", err)
+	}
+	for route, plans := range raw {
+		if limits[route] == nil {
+			limits[route] = make(map[string]RateLimit, len(plans))
+		}
+		for plan, s := range plans {
+			limit, err := ParseRateLimit(s)
+			if err != nil {
+				return nil, fmt.Errorf("RATE_LIMITS %s %s: %w", route, plan, err)
+			}
+			limits[route][plan] = limit
+		}
+	}
+	return limits, nil
+}
+
+// slidingWindow admits a request if fewer than ARGV[2] were admitted in the
+// last ARGV[1] milliseconds, all in one round trip so concurrent requests
+// cannot slip past the limit. It uses the Redis clock, which every API
+// replica shares. It returns whether the request was admitted, how many
+// remain, and in how many milliseconds the oldest request leaves the window.
+var slidingWindow = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+return {0, 0, tonumber(oldest[2]) + window - now}
+`)
+
+// RateDecision is the outcome of one rate limit check.
+type RateDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type RateLimitConfig struct {
+	RedisClient *redis.Client
+	DB          *gorm.DB
+	Limits      RateLimits
+	// PerKey gives every API key its own budget; otherwise the keys of a
+	// tenant share one.
+	PerKey bool
+	// Fallback counts requests while Redis is unavailable.
+	Fallback *RateLimiter
+}
+
+// RateLimitMiddleware limits requests per tenant, or per API key, with the limit
+// configured for the route and the tenant's plan. It must run after
+// APIKeyAuth. Every response carries RateLimit-Limit and
+// RateLimit-Remaining; a 429 also carries Retry-After.
+func RateLimitMiddleware(cfg *RateLimitConfig) gin.HandlerFunc {
+	plans := newPlanCache(repositories.NewTenantRepository(cfg.DB), time.Minute)
+	return func(ctx *gin.Context) {
+		tenantID := GetTenantID(ctx)
+		route := ctx.FullPath()
+		limit, ok := cfg.Limits.Lookup(route, plans.get(tenantID))
+		if !ok {
+			ctx.Next()
+			return
+		}
+
+		subject := "tenant:" + tenantID.String()
+		if key := GetAPIKey(ctx); cfg.PerKey && key != nil {
+			subject = "key:" + key.ID.String()
+		}
+		bucket := "ratelimit:" + route + ":" + subject
+
+		decision, err := allowRedis(ctx, cfg.RedisClient, bucket, limit)
+		if err != nil {
+			metrics.HttpRateLimitFallbackTotal.Inc()
+			decision = cfg.Fallback.Allow(bucket, limit, time.Now())
+		}
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		if !decision.Allowed {
+			metrics.HttpRateLimitRejectionsTotal.Inc()
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func allowRedis(ctx *gin.Context, client *redis.Client, bucket string, limit RateLimit) (RateDecision, error) {
+	res, err := slidingWindow.Run(ctx, client, []string{bucket},
+		limit.Window.Milliseconds(), limit.Requests, uuid.NewString()).Int64Slice()
+	if err != nil {
+		return RateDecision{}, err
+	}
+	if len(res) != 3 {
+		return RateDecision{}, fmt.Errorf("rate limit script returned %d values", len(res))
+	}
+	return RateDecision{
+		Allowed:    res[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// planCache remembers tenant plans for a while so the limiter does not hit
+// Postgres on every request. A tenant whose plan cannot be read is limited
+// as AnyPlan.
+type planCache struct {
+	tenants *repositories.TenantRepository
+	ttl     time.Duration
+	mu      sync.Mutex
+	plans   map[uuid.UUID]cachedPlan
+}
+
+type cachedPlan struct {
+	plan    string
+	expires time.Time
+}
+
+func newPlanCache(tenants *repositories.TenantRepository, ttl time.Duration) *planCache {
+	return &planCache{tenants: tenants, ttl: ttl, plans: make(map[uuid.UUID]cachedPlan)}
+}
+
+func (c *planCache) get(tenantID uuid.UUID) string {
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.plans[tenantID]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.plan
+	}
+
+	tenant, err := c.tenants.GetByID(tenantID)
+	if err != nil {
+		return AnyPlan
+	}
+	c.mu.Lock()
+	c.plans[tenantID] = cachedPlan{plan: tenant.Plan, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return tenant.Plan
+}
@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitsLookup(t *testing.T) {
+	limits, err := RateLimitsFromEnv(`{"/api/notify/": {"free": "10/30s"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		route, plan string
+		want        RateLimit
+	}{
+		{"/api/notify/", "free", RateLimit{10, 30 * time.Second}},
+		// the override adds to the defaults rather than replacing them
+		{"/api/notify/", "standard", DefaultRateLimits[AnyRoute]["standard"]},
+		{"/api/notify/batch", "free", DefaultRateLimits["/api/notify/batch"]["free"]},
+		{"/api/notify/publish", "unknown", DefaultRateLimits[AnyRoute][AnyPlan]},
+	}
+	for _, tc := range cases {
+		got, ok := limits.Lookup(tc.route, tc.plan)
+		if !ok || got != tc.want {
+			t.Errorf("%s %s: got %v (%v), want %v", tc.route, tc.plan, got, ok, tc.want)
+		}
+	}
+
+	for _, bad := range []string{`{"*": {"free": "10"}}`, `{"*": {"free": "0/1m"}}`, `{"*": {"free": "10/soon"}}`, `[]`} {
+		if _, err := RateLimitsFromEnv(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestRateLimiterFallback(t *testing.T) {
+	rl := NewRateLimiter(time.Minute)
+	limit := RateLimit{Requests: 2, Window: time.Second}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if d := rl.Allow("a", limit, now); !d.Allowed {
+			t.Fatalf("request %d rejected", i+1)
+		}
+	}
+	d := rl.Allow("a", limit, now)
+	if d.Allowed {
+		t.Fatal("third request allowed")
+	}
+	if d.RetryAfter <= 0 || d.RetryAfter > limit.Window {
+		t.Errorf("retry after %v", d.RetryAfter)
+	}
+	if d := rl.Allow("b", limit, now); !d.Allowed {
+		t.Error("buckets are not separate")
+	}
+	if d := rl.Allow("a", limit, now.Add(limit.Window)); !d.Allowed {
+		t.Error("bucket did not refill")
+	}
+
+	// both buckets have been idle for a minute
+	rl.Allow("c", limit, now.Add(2*time.Minute))
+	if n := rl.Len(); n != 1 {
+		t.Errorf("idle buckets kept: %d", n)
+	}
+}
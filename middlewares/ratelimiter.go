@@ -1,10 +1,9 @@
 package middlewares
 
 import (
-	"net/http"
 	"sync"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
 type NotifyRequest struct {
@@ -13,47 +12,74 @@ type NotifyRequest struct {
     IdempotencyKey string                 `json:"idempotency_key" binding:"required"`
 }
 
-
+// RateLimiter is the in-process token bucket the rate limit middleware falls
+// back to while Redis is unreachable. Its counts are per replica, so the
+// effective limit is multiplied by the number of API instances until Redis
+// is back. Buckets idle for longer than the idle timeout are dropped.
 type RateLimiter struct {
-	limiters map[string]*rate.Limiter
-	mu       sync.Mutex
-	r        rate.Limit
-	burst    int
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	limit    RateLimit
+	lastSeen time.Time
 }
 
-func NewRateLimiter(r rate.Limit, burst int) *RateLimiter {
+func NewRateLimiter(idle time.Duration) *RateLimiter {
 	return &RateLimiter{
-		limiters: make(map[string]*rate.Limiter),
-		r:        r,
-		burst:    burst,
+		buckets: make(map[string]*bucket),
+		idle:    idle,
 	}
 }
 
-func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
+// Allow takes one request from key's bucket, refilled at limit.
+func (rl *RateLimiter) Allow(key string, limit RateLimit, now time.Time) RateDecision {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	limiter, exists := rl.limiters[key]
-	if !exists {
-		limiter = rate.NewLimiter(rl.r, rl.burst)
-		rl.limiters[key] = limiter
+	rl.sweep(now)
+	b, ok := rl.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{
+			limiter: rate.NewLimiter(rate.Every(limit.Window/time.Duration(limit.Requests)), limit.Requests),
+			limit:   limit,
+		}
+		rl.buckets[key] = b
 	}
-	return limiter
-}
+	b.lastSeen = now
 
-func (rl *RateLimiter) Middleware(key string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		
+	r := b.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return RateDecision{Limit: limit.Requests, RetryAfter: delay}
+	}
+	remaining := int(b.limiter.TokensAt(now))
+	if remaining < 0 {
+		remaining = 0
+	}
+	return RateDecision{Allowed: true, Limit: limit.Requests, Remaining: remaining}
+}
 
-		limiter := rl.getLimiter(key)
-		if !limiter.Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error": "rate limit exceeded, slow down",
-			})
-			return
+// sweep drops idle buckets, at most once per idle period.
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.idle {
+		return
+	}
+	rl.lastSweep = now
+	for key, b := range rl.buckets {
+		if now.Sub(b.lastSeen) >= rl.idle {
+			delete(rl.buckets, key)
 		}
-		c.Next()
 	}
 }
 
-
+// Len is the number of buckets held.
+func (rl *RateLimiter) Len() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.buckets)
+}
@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const tenantContextKey = "tenant_id"
+
+func SetTenantID(ctx *gin.Context, tenantID uuid.UUID) {
+	ctx.Set(tenantContextKey, tenantID)
+}
+
+func GetTenantID(ctx *gin.Context) uuid.UUID {
+	if v, ok := ctx.Get(tenantContextKey); ok {
+		if id, ok := v.(uuid.UUID); ok {
+			return id
+		}
+	}
+	return uuid.Nil
+}
@@ -0,0 +1,113 @@
+// Package collapse keeps track of collapse windows in Redis. The first
+// notification for a user, topic, channel and collapse key opens a window;
+// notifications arriving before it closes are collapsed, either into the
+// first one or, in replace mode, the earlier ones into the latest.
+package collapse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// claim opens the window for ARGV[1] unless one is open. In replace mode
+// the newcomer takes the window over without extending it. It returns the
+// notification that held the window before, or "", and the window's
+// remaining milliseconds.
+var claim = redis.NewScript(`
+local prev = redis.call("GET", KEYS[1])
+if not prev then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return {"", tonumber(ARGV[2])}
+end
+if ARGV[3] == "replace" then
+	redis.call("SET", KEYS[1], ARGV[1], "KEEPTTL")
+end
+return {prev, redis.call("PTTL", KEYS[1])}
+`)
+
+// release hands the window back from ARGV[1] to ARGV[2], or closes it when
+// ARGV[2] is "". A window someone else has taken over since is left alone.
+var release = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "" then
+	redis.call("DEL", KEYS[1])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "KEEPTTL")
+end
+return 1
+`)
+
+// Claim is where a notification stands in its collapse window.
+type Claim struct {
+	// Previous held the window before; uuid.Nil when this notification
+	// opened it.
+	Previous uuid.UUID
+	// Closes is when the window ends.
+	Closes time.Time
+}
+
+type Store struct {
+	rdb *redis.Client
+}
+
+func NewStore(rdb *redis.Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+// Key identifies a collapse group.
+func Key(tenantID uuid.UUID, userRef, topic, channel, collapseKey string) string {
+	return strings.Join([]string{"collapse", tenantID.String(), userRef, topic, channel, collapseKey}, ":")
+}
+
+// Claim enters id into the window for key, opening one of length window if
+// none is open.
+func (s *Store) Claim(ctx context.Context, key string, id uuid.UUID, window time.Duration, mode string, now time.Time) (Claim, error) {
+	res, err := claim.Run(ctx, s.rdb, []string{key}, id.String(), window.Milliseconds(), mode).Slice()
+	if err != nil {
+		return Claim{}, err
+	}
+	if len(res) != 2 {
+		return Claim{}, fmt.Errorf("collapse: unexpected reply %v", res)
+	}
+	c := Claim{Closes: now.Add(window)}
+	if ms, ok := res[1].(int64); ok && ms > 0 {
+		c.Closes = now.Add(time.Duration(ms) * time.Millisecond)
+	}
+	if prev, _ := res[0].(string); prev != "" {
+		if c.Previous, err = uuid.Parse(prev); err != nil {
+			return Claim{}, fmt.Errorf("collapse: stored id: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Release takes id out of the window for key, for a notification that was
+// never stored. The window goes back to previous, the notification that held
+// it before, or closes when previous is uuid.Nil.
+func (s *Store) Release(ctx context.Context, key string, id, previous uuid.UUID) error {
+	prev := ""
+	if previous != uuid.Nil {
+		prev = previous.String()
+	}
+	return release.Run(ctx, s.rdb, []string{key}, id.String(), prev).Err()
+}
+
+// Resolve picks the collapse rule for a request: the request's own window
+// and mode when it sets a window, the policy's otherwise.
+func Resolve(request, policy models.CollapseRule) models.CollapseRule {
+	if !request.IsZero() {
+		if request.Mode == "" {
+			request.Mode = policy.Mode
+		}
+		return request
+	}
+	return policy
+}
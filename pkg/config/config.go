@@ -40,8 +40,37 @@ func LoadConfig(path string) (*Config, error) {
 }
 
 func BuildMailer(cfg *Config) (gomailer.Mailer, error) {
-	
-	switch cfg.Email.Provider {
+	return buildMailer(cfg, cfg.Email.Provider)
+}
+
+// BuildMailers builds the configured default provider and every other
+// provider that has a config section, keyed by provider name, so a policy
+// rule can route to a non-default one.
+func BuildMailers(cfg *Config) (map[string]gomailer.Mailer, error) {
+	def, err := BuildMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mailers := map[string]gomailer.Mailer{cfg.Email.Provider: def}
+	configured := map[string]bool{
+		"smtp":     cfg.Email.SMTP != nil,
+		"sendgrid": cfg.Email.SendGrid != nil,
+	}
+	for name, ok := range configured {
+		if !ok || mailers[name] != nil {
+			continue
+		}
+		m, err := buildMailer(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		mailers[name] = m
+	}
+	return mailers, nil
+}
+
+func buildMailer(cfg *Config, provider string) (gomailer.Mailer, error) {
+	switch provider {
 	case "smtp":
 		if cfg.Email.SMTP == nil {
 			return nil, fmt.Errorf("missing smtp config for email provider")
@@ -70,26 +99,51 @@ func BuildMailer(cfg *Config) (gomailer.Mailer, error) {
 		}, nil
 
 	default:
-		return nil, fmt.Errorf("unsupported email provider: %s", cfg.Email.Provider)
+		return nil, fmt.Errorf("unsupported email provider: %s", provider)
 	}
 }
 
 func BuildSender(cfg *Config) (gosms.Sender, error) {
-		
-	switch cfg.SMS.Provider {
+	return buildSender(cfg, cfg.SMS.Provider)
+}
+
+// BuildSenders is BuildMailers for SMS.
+func BuildSenders(cfg *Config) (map[string]gosms.Sender, error) {
+	def, err := BuildSender(cfg)
+	if err != nil {
+		return nil, err
+	}
+	senders := map[string]gosms.Sender{cfg.SMS.Provider: def}
+	if cfg.SMS.Twilio != nil && senders["twilio"] == nil {
+		s, err := buildSender(cfg, "twilio")
+		if err != nil {
+			return nil, err
+		}
+		senders["twilio"] = s
+	}
+	return senders, nil
+}
+
+func buildSender(cfg *Config, provider string) (gosms.Sender, error) {
+	switch provider {
 		case "twilio":
 			if cfg.SMS.Twilio == nil {
 				return nil, fmt.Errorf("missing sms config for sms provider")
 			}
+			statusCallback := cfg.SMS.Twilio.StatusCallback
+			if statusCallback == "" {
+				statusCallback = os.Getenv("TWILIO_STATUS_CALLBACK_URL")
+			}
 			return &gosms.TwilioSender{
 				Provider :"twilio",
 				FromNumber: cfg.SMS.Twilio.FromNumber,
 				Username: cfg.SMS.Twilio.Username,
 				Password: cfg.SMS.Twilio.Password,
+				StatusCallback: statusCallback,
 
 			},nil
 	default:
-		return nil, fmt.Errorf("unsupported email provider: %s", cfg.Email.Provider)
+		return nil, fmt.Errorf("unsupported sms provider: %s", provider)
 	}
 }
 
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+// DedupePolicies makes way for the unique index on (tenant_id, topic): where
+// a tenant has several policies for a topic, the newest stays and the others
+// are deleted along with their rules and versions. Each deleted policy is
+// logged. It does nothing once the index exists, and runs after
+// BackfillTenants and before Policy is migrated.
+func DedupePolicies(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable("policies") || m.HasIndex(&models.Policy{}, "idx_policy_topic") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var stale []struct {
+			ID       uuid.UUID
+			TenantID uuid.UUID
+			Topic    string
+		}
+		if err := tx.Raw(`SELECT id, tenant_id, topic FROM (
+			SELECT id, tenant_id, topic,
+				ROW_NUMBER() OVER (PARTITION BY tenant_id, topic ORDER BY created_at DESC, id DESC) AS n
+			FROM policies) ranked
+			WHERE n > 1`).Scan(&stale).Error; err != nil {
+			return fmt.Errorf("find duplicate policies: %w", err)
+		}
+		if len(stale) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(stale))
+		for i, p := range stale {
+			ids[i] = p.ID
+			log.Printf("deleting duplicate policy %s for topic %q of tenant %s", p.ID, p.Topic, p.TenantID)
+		}
+		for _, table := range []string{"policy_rules", "policy_versions"} {
+			if !tx.Migrator().HasTable(table) {
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE policy_id IN ?", table), ids).Error; err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+		}
+		return tx.Exec("DELETE FROM policies WHERE id IN ?", ids).Error
+	})
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+)
+
+func TestDedupePoliciesKeepsNewestPerTopic(t *testing.T) {
+	db := legacyDB(t)
+	tenant := uuid.New()
+	older, newer, other := uuid.New(), uuid.New(), uuid.New()
+	for _, step := range []struct {
+		sql  string
+		args []interface{}
+	}{
+		{sql: `CREATE TABLE policies (id uuid PRIMARY KEY, tenant_id uuid NOT NULL, topic varchar(100) NOT NULL,
+			channels text[] NOT NULL, locale varchar(100) NOT NULL, created_at timestamptz)`},
+		{sql: `CREATE TABLE policy_rules (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), policy_id uuid NOT NULL,
+			condition text NOT NULL, channels text[] NOT NULL)`},
+		{sql: `INSERT INTO policies VALUES (?, ?, 'order.shipped', '{email}', 'en-US', now() - interval '1 day'),
+			(?, ?, 'order.shipped', '{sms}', 'en-US', now()), (?, ?, 'order.paid', '{email}', 'en-US', now())`,
+			args: []interface{}{older, tenant, newer, tenant, other, tenant}},
+		{sql: `INSERT INTO policy_rules (policy_id, condition, channels) VALUES (?, 'true', '{email}')`,
+			args: []interface{}{older}},
+	} {
+		if err := db.Exec(step.sql, step.args...).Error; err != nil {
+			t.Fatalf("%s: %v", step.sql, err)
+		}
+	}
+
+	if err := DedupePolicies(db); err != nil {
+		t.Fatalf("dedupe: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Policy{}, &models.PolicyRule{}); err != nil {
+		t.Fatalf("migrate after dedupe: %v", err)
+	}
+
+	var ids []uuid.UUID
+	if err := db.Table("policies").Order("topic").Pluck("id", &ids).Error; err != nil {
+		t.Fatalf("list policies: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != other || ids[1] != newer {
+		t.Errorf("expected %s and %s to stay, got %v", other, newer, ids)
+	}
+	var rules int64
+	if err := db.Table("policy_rules").Where("policy_id = ?", older).Count(&rules).Error; err != nil {
+		t.Fatalf("count rules: %v", err)
+	}
+	if rules != 0 {
+		t.Errorf("expected the deleted policy's rules to go, %d left", rules)
+	}
+}
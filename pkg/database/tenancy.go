@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+// LegacyTenantName names the tenant that is given the rows stored before
+// notifications, templates and policies were scoped by tenant.
+const LegacyTenantName = "default"
+
+// tenantScopedTables predate tenants and gained a tenant_id column with them.
+var tenantScopedTables = []string{"notifications", "delivery_attempts", "templates", "policies", "idempotency_keys"}
+
+// BackfillTenants gives the tables that predate tenants their tenant_id
+// column. AutoMigrate would add it NOT NULL without a default, which fails on
+// a table that has rows, so the column is added nullable, filled with the
+// legacy tenant and only then made NOT NULL. idempotency_keys also moves its
+// primary key to (tenant_id, key). Tables that have the column already, or
+// do not exist yet, are left to AutoMigrate. It runs after Tenant is migrated
+// and before the tenant-scoped models.
+func BackfillTenants(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var legacy *models.Tenant
+		for _, table := range tenantScopedTables {
+			if !tx.Migrator().HasTable(table) || tx.Migrator().HasColumn(table, "tenant_id") {
+				continue
+			}
+			if legacy == nil {
+				legacy = &models.Tenant{}
+				if err := tx.Where(models.Tenant{Name: LegacyTenantName}).FirstOrCreate(legacy).Error; err != nil {
+					return fmt.Errorf("legacy tenant: %w", err)
+				}
+			}
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN tenant_id uuid", table)).Error; err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+			if err := tx.Table(table).Where("tenant_id IS NULL").Update("tenant_id", legacy.ID).Error; err != nil {
+				return fmt.Errorf("%s: %w", table, err)
+			}
+			steps := []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN tenant_id SET NOT NULL", table)}
+			if table == "idempotency_keys" {
+				steps = append(steps,
+					"ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey",
+					"ALTER TABLE idempotency_keys ADD PRIMARY KEY (tenant_id, key)",
+				)
+			}
+			for _, sql := range steps {
+				if err := tx.Exec(sql).Error; err != nil {
+					return fmt.Errorf("%s: %w", table, err)
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// legacyDB opens SIGNALBUS_TEST_DB in a transaction that is rolled back when
+// the test ends, with an empty schema of its own. The test is skipped when
+// the variable is unset.
+func legacyDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("SIGNALBUS_TEST_DB")
+	if dsn == "" {
+		t.Skip("SIGNALBUS_TEST_DB is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	for _, sql := range []string{"CREATE SCHEMA signalbus_legacy", "SET LOCAL search_path TO signalbus_legacy"} {
+		if err := tx.Exec(sql).Error; err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	return tx
+}
+
+func TestBackfillTenantsMigratesPopulatedTables(t *testing.T) {
+	db := legacyDB(t)
+	// the tables as they were before tenants
+	for _, sql := range []string{
+		`CREATE TABLE notifications (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), topic varchar(100) NOT NULL,
+			channel varchar(100) NOT NULL, user_ref varchar(100), status varchar(50) NOT NULL, created_at timestamptz)`,
+		`CREATE TABLE idempotency_keys (key varchar(64) PRIMARY KEY, request_hash text NOT NULL, response jsonb,
+			status_code bigint NOT NULL, created_at timestamptz)`,
+		`INSERT INTO notifications (topic, channel, status) VALUES ('order.shipped', 'email', 'delivered')`,
+		`INSERT INTO idempotency_keys (key, request_hash, status_code) VALUES ('k1', 'h1', 202)`,
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	if err := db.AutoMigrate(&models.Tenant{}); err != nil {
+		t.Fatalf("migrate tenants: %v", err)
+	}
+	if err := BackfillTenants(db); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Notification{}, &models.IdempotencyKey{}); err != nil {
+		t.Fatalf("migrate after backfill: %v", err)
+	}
+
+	var legacy models.Tenant
+	if err := db.First(&legacy, "name = ?", LegacyTenantName).Error; err != nil {
+		t.Fatalf("legacy tenant: %v", err)
+	}
+	for _, table := range []string{"notifications", "idempotency_keys"} {
+		var owners []uuid.UUID
+		if err := db.Table(table).Pluck("tenant_id", &owners).Error; err != nil {
+			t.Fatalf("%s: %v", table, err)
+		}
+		if len(owners) != 1 || owners[0] != legacy.ID {
+			t.Errorf("%s: expected the row to belong to %s, got %v", table, legacy.ID, owners)
+		}
+	}
+	// running it again is a no-op
+	if err := BackfillTenants(db); err != nil {
+		t.Fatalf("second backfill: %v", err)
+	}
+}
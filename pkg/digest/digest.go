@@ -0,0 +1,187 @@
+// Package digest sends the notifications buffered for digest policies. Once
+// a digest window closes, the buffered items of each user, topic and
+// channel are rendered into one message with the policy's digest template
+// and handed to the channel worker through the outbox like any other
+// notification.
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jsndz/signalbus/pkg/lanes"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/outbox"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/jsndz/signalbus/pkg/templates"
+	"github.com/jsndz/signalbus/pkg/types"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Config struct {
+	// BatchSize is how many digests one tick sends at most.
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:    100,
+		PollInterval: 30 * time.Second,
+	}
+}
+
+// Job builds digests. Each one is claimed with FOR UPDATE SKIP LOCKED, so
+// every API replica can run a Job.
+type Job struct {
+	notifications *repositories.NotificationRepository
+	policies      *repositories.PolicyRepository
+	templates     *repositories.TemplateRepository
+	logger        *zap.Logger
+	cfg           Config
+}
+
+func New(db *gorm.DB, logger *zap.Logger, cfg Config) *Job {
+	return &Job{
+		notifications: repositories.NewNotificationRepository(db),
+		policies:      repositories.NewPolicyRepository(db),
+		templates:     repositories.NewTemplateRepository(db),
+		logger:        logger,
+		cfg:           cfg,
+	}
+}
+
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.PollInterval)
+	defer ticker.Stop()
+
+	j.logger.Info("Digest job started", zap.Duration("poll_interval", j.cfg.PollInterval))
+	for {
+		select {
+		case <-ctx.Done():
+			j.logger.Info("Digest job stopped")
+			return
+		case <-ticker.C:
+			for i := 0; i < j.cfg.BatchSize; i++ {
+				sent, err := j.sendNext(ctx, time.Now())
+				if err != nil {
+					j.logger.Error("digest failed", zap.Error(err))
+					break
+				}
+				if !sent {
+					break
+				}
+			}
+		}
+	}
+}
+
+// sendNext sends one due digest. It reports false when none is due.
+func (j *Job) sendNext(ctx context.Context, now time.Time) (bool, error) {
+	headers, err := outbox.TraceHeaders(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	sent := false
+	err = j.notifications.Transaction(func(tx *repositories.NotificationRepository) error {
+		items, err := tx.LockDueDigest(now)
+		if err != nil || len(items) == 0 {
+			return err
+		}
+		sent = true
+		last := items[len(items)-1]
+
+		digest := &models.Notification{
+			TenantID:    last.TenantID,
+			Topic:       last.Topic,
+			Channel:     last.Channel,
+			UserRef:     last.UserRef,
+			Priority:    lanes.Bulk,
+			Status:      "pending",
+			RuleOutcome: fmt.Sprintf("digest of %d notifications", len(items)),
+		}
+		content, renderErr := j.render(items)
+		if renderErr != nil {
+			// keep the items from coming due forever; the reason is on the
+			// digest notification
+			digest.Status = "failed"
+			digest.StatusReason = renderErr.Error()
+		}
+		if err := tx.Create(digest); err != nil {
+			return err
+		}
+		if err := tx.MarkDigested(items, digest.ID); err != nil {
+			return err
+		}
+
+		log := j.logger.With(
+			zap.String("digest_id", digest.ID.String()),
+			zap.String("user_ref", digest.UserRef),
+			zap.String("topic", digest.Topic),
+			zap.Int("items", len(items)),
+		)
+		if renderErr != nil {
+			log.Error("could not render digest", zap.Error(renderErr))
+			return nil
+		}
+
+		key := "digest:" + digest.ID.String()
+		out, err := outbox.NewMessage(digest, key, types.KafkaStreamData{
+			TenantID:       digest.TenantID,
+			NotificationId: digest.ID,
+			IdempotencyKey: key,
+			RecieverData:   last.Recipient,
+			HTMLMessage:    string(content["html"]),
+			TextMessage:    string(content["text"]),
+		}, headers)
+		if err != nil {
+			return err
+		}
+		if err := tx.CreateOutbox(out); err != nil {
+			return err
+		}
+		log.Info("digest queued")
+		return nil
+	})
+	return sent, err
+}
+
+// render renders the digest template. Its data carries the buffered events
+// under "events", each with the template data it was sent with, plus
+// "count", "topic" and "user_ref".
+func (j *Job) render(items []models.DigestItem) (map[string][]byte, error) {
+	last := items[len(items)-1]
+	policy, err := j.policies.GetByTopic(last.TenantID, last.Topic)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("no policy for topic %q", last.Topic)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		events[i] = map[string]interface{}{
+			"notification_id": item.NotificationID.String(),
+			"data":            map[string]interface{}(item.Data),
+			"created_at":      item.CreatedAt,
+		}
+	}
+	data := map[string]interface{}{
+		"events":   events,
+		"count":    len(items),
+		"topic":    last.Topic,
+		"user_ref": last.UserRef,
+	}
+
+	contentTypes := []string{"text"}
+	if last.Channel == "email" {
+		contentTypes = []string{"html", "text"}
+	}
+	return templates.Render(data, last.TenantID, last.Channel,
+		policy.Digest.TemplateFor(last.Topic), last.Locale, contentTypes, j.templates)
+}
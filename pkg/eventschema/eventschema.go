@@ -0,0 +1,119 @@
+// Package eventschema validates notify payloads against the JSON Schema a
+// tenant registered for the event type.
+//
+// The schema describes one object with two properties, "data" and
+// "template_data", holding the request's fields of the same name:
+//
+//	{
+//	  "type": "object",
+//	  "properties": {
+//	    "data": {"type": "object", "required": ["to"]},
+//	    "template_data": {"type": "object", "required": ["name"]}
+//	  }
+//	}
+package eventschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const resourceURL = "mem://event-schema.json"
+
+var printer = message.NewPrinter(language.English)
+
+// Violation is one way a payload fails its schema. Path is a JSON pointer
+// into the validated object, e.g. /data/amount.
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every violation found in a payload.
+type ValidationError struct {
+	EventType  string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("payload does not match the schema for %q (%d violations)", e.EventType, len(e.Violations))
+}
+
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Compile parses and checks a schema document. $ref is limited to the
+// document itself; nothing is loaded from files or the network.
+func Compile(raw string) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	c := jsonschema.NewCompiler()
+	c.UseLoader(jsonschema.SchemeURLLoader{})
+	if err := c.AddResource(resourceURL, doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	s, err := c.Compile(resourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Schema{schema: s}, nil
+}
+
+// Validate checks data and templateData. It returns nil or a
+// *ValidationError naming every violation.
+func (s *Schema) Validate(eventType string, data, templateData map[string]interface{}) error {
+	payload := map[string]interface{}{}
+	if data != nil {
+		payload["data"] = data
+	}
+	if templateData != nil {
+		payload["template_data"] = templateData
+	}
+	// Round trip through JSON so numbers arrive as the library expects them.
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	err = s.schema.Validate(inst)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	var violations []Violation
+	collect(verr, &violations)
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return &ValidationError{EventType: eventType, Violations: violations}
+}
+
+// collect gathers the leaves of the error tree; inner nodes only say that
+// some child failed.
+func collect(e *jsonschema.ValidationError, out *[]Violation) {
+	if len(e.Causes) == 0 {
+		*out = append(*out, Violation{
+			Path:    "/" + strings.Join(e.InstanceLocation, "/"),
+			Message: e.ErrorKind.LocalizedString(printer),
+		})
+		return
+	}
+	for _, cause := range e.Causes {
+		collect(cause, out)
+	}
+}
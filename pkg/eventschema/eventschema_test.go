@@ -0,0 +1,73 @@
+package eventschema
+
+import (
+	"errors"
+	"testing"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"properties": {
+		"data": {
+			"type": "object",
+			"required": ["to"],
+			"properties": {"amount": {"type": "number", "minimum": 0}}
+		},
+		"template_data": {
+			"type": "object",
+			"required": ["name"]
+		}
+	},
+	"required": ["data"]
+}`
+
+func TestValidateListsEveryViolation(t *testing.T) {
+	s, err := Compile(orderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Validate("order_paid",
+		map[string]interface{}{"amount": -5},
+		map[string]interface{}{},
+	)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	paths := map[string]bool{}
+	for _, v := range verr.Violations {
+		paths[v.Path] = true
+	}
+	for _, want := range []string{"/data", "/data/amount", "/template_data"} {
+		if !paths[want] {
+			t.Errorf("missing violation at %s, got %+v", want, verr.Violations)
+		}
+	}
+}
+
+func TestValidatePasses(t *testing.T) {
+	s, err := Compile(orderSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Validate("order_paid",
+		map[string]interface{}{"to": "+15550100", "amount": 12.5},
+		map[string]interface{}{"name": "Ada"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompileRejectsBadSchemas(t *testing.T) {
+	for _, raw := range []string{
+		`{`,
+		`{"type": 12}`,
+		`{"$ref": "file:///etc/passwd"}`,
+	} {
+		if _, err := Compile(raw); err == nil {
+			t.Errorf("Compile(%s) succeeded", raw)
+		}
+	}
+}
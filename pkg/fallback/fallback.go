@@ -0,0 +1,245 @@
+// Package fallback moves a notification to the next channel of its policy's
+// fallback chain once a worker has given up on it. Workers publish a
+// FallbackEvent to Topic; the Coordinator consumes it and stores the next
+// channel's notification, linked to the failed one, through the outbox.
+package fallback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/kafka"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/outbox"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/jsndz/signalbus/pkg/types"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const Topic = "notification.fallback"
+
+// Publish tells the coordinator that notificationID failed permanently on
+// channel.
+func Publish(ctx context.Context, producer *kafka.Producer, tenantID, notificationID uuid.UUID, channel, reason string) error {
+	body, err := json.Marshal(types.FallbackEvent{
+		TenantID:       tenantID,
+		NotificationID: notificationID,
+		Channel:        channel,
+		Reason:         reason,
+		FailedAt:       time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return producer.Publish(ctx, Topic, notificationID[:], body)
+}
+
+type Coordinator struct {
+	notifications *repositories.NotificationRepository
+	policies      *repositories.PolicyRepository
+	contacts      *repositories.ContactRepository
+	preferences   *repositories.PreferenceRepository
+	suppressions  *repositories.SuppressionRepository
+	usage         *repositories.UsageRepository
+	logger        *zap.Logger
+}
+
+func New(db *gorm.DB, logger *zap.Logger) *Coordinator {
+	return &Coordinator{
+		notifications: repositories.NewNotificationRepository(db),
+		policies:      repositories.NewPolicyRepository(db),
+		contacts:      repositories.NewContactRepository(db),
+		preferences:   repositories.NewPreferenceRepository(db),
+		suppressions:  repositories.NewSuppressionRepository(db),
+		usage:         repositories.NewUsageRepository(db),
+		logger:        logger,
+	}
+}
+
+// Run consumes fallback events until ctx is cancelled.
+func (c *Coordinator) Run(ctx context.Context) {
+	consumer := kafka.NewConsumerFromEnv(Topic, "fallback")
+	defer consumer.Close()
+
+	c.logger.Info("Fallback coordinator started")
+	for {
+		m, err := consumer.ReadFromKafka(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.logger.Info("Fallback coordinator stopped")
+				return
+			}
+			c.logger.Error("failed to read fallback event", zap.Error(err))
+			time.Sleep(time.Second)
+			continue
+		}
+
+		var ev types.FallbackEvent
+		if err := json.Unmarshal(m.Value, &ev); err != nil {
+			c.logger.Error("invalid fallback event", zap.ByteString("raw", m.Value), zap.Error(err))
+			continue
+		}
+		if err := c.Handle(ctx, ev); err != nil {
+			c.logger.Error("fallback failed",
+				zap.String("notification_id", ev.NotificationID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// Handle creates the fallback for ev, if the policy has one. It does nothing
+// unless the original is still failed, so a notification that was delivered
+// after all, or redrove successfully, never falls back. The fallback goes
+// through the same checks as a notify request: a channel the user opted out
+// of, unless the policy is transactional, or a suppressed address stores it
+// as suppressed, and a tenant out of quota gets none.
+func (c *Coordinator) Handle(ctx context.Context, ev types.FallbackEvent) error {
+	log := c.logger.With(zap.String("notification_id", ev.NotificationID.String()))
+
+	original, err := c.notifications.GetByID(ev.TenantID, ev.NotificationID)
+	if err != nil {
+		return err
+	}
+	if original.Status != "failed" {
+		log.Info("skipping fallback", zap.String("status", original.Status))
+		return nil
+	}
+
+	policy, err := c.policies.GetByTopic(original.TenantID, original.Topic)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	next := policy.NextFallback(original.Channel)
+	if next == "" {
+		return nil
+	}
+
+	contact, err := c.contacts.GetByUser(original.TenantID, original.UserRef)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Warn("no contact to fall back to", zap.String("channel", next))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	addr, ok := contact.Address(next)
+	if !ok {
+		log.Warn("contact has no address for fallback channel", zap.String("channel", next))
+		return nil
+	}
+
+	prev, err := c.notifications.LatestOutbox(original.TenantID, original.ID)
+	if err != nil {
+		return err
+	}
+	var msg types.KafkaStreamData
+	if err := json.Unmarshal(prev.Payload, &msg); err != nil {
+		return err
+	}
+
+	headers, err := outbox.TraceHeaders(ctx)
+	if err != nil {
+		return err
+	}
+
+	fallbackOf := original.ID
+	n := &models.Notification{
+		TenantID:    original.TenantID,
+		Topic:       original.Topic,
+		Channel:     next,
+		UserRef:     original.UserRef,
+		Priority:    original.Priority,
+		Status:      "pending",
+		RuleOutcome: "fallback from " + original.Channel,
+		FallbackOf:  &fallbackOf,
+	}
+	now := time.Now()
+	if !policy.Transactional {
+		prefs, err := c.preferences.ListByUser(original.TenantID, original.UserRef)
+		if err != nil {
+			return err
+		}
+		if !prefs.Allows(original.Topic, next) {
+			n.Status = "suppressed"
+			n.StatusReason = "user opted out of " + next
+		}
+	}
+	if n.Status == "pending" {
+		_, hit, err := c.suppressions.Filter(original.TenantID, next, []string{addr}, now)
+		if err != nil {
+			return err
+		}
+		if hit != nil {
+			n.Status = "suppressed"
+			n.StatusReason = "recipient suppressed: " + hit.Reason
+		}
+	}
+	if n.Status == "pending" {
+		err := c.usage.CheckQuota(original.TenantID, map[string]int64{next: 1}, now)
+		var exceeded *repositories.QuotaError
+		if errors.As(err, &exceeded) {
+			log.Warn("quota exhausted, no fallback", zap.String("channel", next), zap.Error(err))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	key := string(prev.Key)
+	if key != "" {
+		key += ":" + next
+	}
+
+	created := false
+	err = c.notifications.Transaction(func(tx *repositories.NotificationRepository) error {
+		ok, err := tx.CreateFallback(n)
+		if err != nil || !ok {
+			return err
+		}
+		created = true
+		if n.Status != "pending" {
+			// recorded for the audit trail only
+			return nil
+		}
+		if err := tx.RecordAccepted(n.TenantID, n.Channel, 1); err != nil {
+			return err
+		}
+
+		reciever := make(map[string]interface{}, len(msg.RecieverData))
+		for k, v := range msg.RecieverData {
+			reciever[k] = v
+		}
+		types.SetRecipient(reciever, next, addr)
+		msg.RecieverData = reciever
+		msg.NotificationId = n.ID
+		msg.IdempotencyKey = key
+		// an override names a provider of the failed channel
+		msg.Provider = ""
+
+		out, err := outbox.NewMessage(n, key, msg, headers)
+		if err != nil {
+			return err
+		}
+		return tx.CreateOutbox(out)
+	})
+	if err != nil {
+		return err
+	}
+	if created {
+		log.Info("fallback created",
+			zap.String("from", original.Channel),
+			zap.String("to", next),
+			zap.String("fallback_id", n.ID.String()),
+			zap.String("status", n.Status),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package gate holds the checks a channel worker makes right before it
+// contacts a provider. Conditions can change between the API accepting a
+// notification and a worker consuming it (a redrive at night, a backlog that
+// drains late), so the API's own checks are not enough.
+package gate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jsndz/signalbus/pkg/outbox"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"github.com/jsndz/signalbus/pkg/types"
+	"gorm.io/gorm"
+)
+
+type Action int
+
+const (
+	// Send delivers the message now.
+	Send Action = iota
+	// Defer holds the message until Decision.Until.
+	Defer
+)
+
+type Decision struct {
+	Action Action
+	Until  time.Time
+	Reason string
+}
+
+type Gate struct {
+	notifications *repositories.NotificationRepository
+	policies      *repositories.PolicyRepository
+	quietHours    *repositories.QuietHoursRepository
+}
+
+func New(db *gorm.DB) *Gate {
+	return &Gate{
+		notifications: repositories.NewNotificationRepository(db),
+		policies:      repositories.NewPolicyRepository(db),
+		quietHours:    repositories.NewQuietHoursRepository(db),
+	}
+}
+
+// Check decides what to do with msg at now. When it cannot load what it
+// needs it returns Send along with the error, so a database hiccup delays
+// nothing.
+func (g *Gate) Check(msg types.KafkaStreamData, now time.Time) (Decision, error) {
+	send := Decision{Action: Send}
+
+	n, err := g.notifications.GetByID(msg.TenantID, msg.NotificationId)
+	if err != nil {
+		return send, err
+	}
+	// Publish does not go through a policy, so there may be none.
+	policy, err := g.policies.GetByTopic(n.TenantID, n.Topic)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		policy, err = nil, nil
+	}
+	if err != nil {
+		return send, err
+	}
+	if policy != nil && policy.Urgent {
+		return send, nil
+	}
+
+	window, err := g.quietHours.EffectiveWindow(n.TenantID, n.UserRef, policy)
+	if err != nil {
+		return send, err
+	}
+	if until, ok := window.DeferUntil(now); ok {
+		return Decision{Action: Defer, Until: until.UTC(), Reason: "quiet hours"}, nil
+	}
+	return send, nil
+}
+
+// ErrFinal is returned by Hold for a notification a worker has finished
+// with: delivered, sent, failed, cancelled and the like. A redelivered
+// message for it must be dropped, not sent again.
+var ErrFinal = errors.New("gate: notification is final")
+
+// Hold marks the notification deferred and writes a held outbox message that
+// the scheduler releases at until. It returns ErrFinal when the notification
+// can no longer be deferred.
+func (g *Gate) Hold(ctx context.Context, msg types.KafkaStreamData, key []byte, d Decision) error {
+	headers, err := outbox.TraceHeaders(ctx)
+	if err != nil {
+		return err
+	}
+	return g.notifications.Transaction(func(tx *repositories.NotificationRepository) error {
+		deferred, err := tx.Defer(msg.TenantID, msg.NotificationId, d.Until, d.Reason)
+		if err != nil {
+			return err
+		}
+		if !deferred {
+			return ErrFinal
+		}
+		// a redelivered message may find its notification held already
+		rescheduled, err := tx.RescheduleHeld(msg.TenantID, msg.NotificationId, d.Until)
+		if err != nil || rescheduled {
+			return err
+		}
+		n, err := tx.GetByID(msg.TenantID, msg.NotificationId)
+		if err != nil {
+			return err
+		}
+		out, err := outbox.NewMessage(n, string(key), msg, headers)
+		if err != nil {
+			return err
+		}
+		out.Status = "scheduled"
+		out.AvailableAt = d.Until
+		return tx.CreateOutbox(out)
+	})
+}
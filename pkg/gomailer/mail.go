@@ -21,6 +21,9 @@ type Email struct{
 	IdempotencyKey string
 	Attachments []string
 	Headers map[string]string   
+	// CustomArgs travel with the message and come back on the provider's
+	// event webhooks; the worker sets notification_id and tenant_id.
+	CustomArgs map[string]string `json:"custom_args,omitempty"`
 }
 
 
@@ -62,6 +65,15 @@ func WithAttachments(files ...string) EmailOption {
 	}
 }
 
+func WithCustomArg(key, value string) EmailOption {
+	return func(e *Email) {
+		if e.CustomArgs == nil {
+			e.CustomArgs = make(map[string]string)
+		}
+		e.CustomArgs[key] = value
+	}
+}
+
 func Header(key,value string) EmailOption {
 	return func(e *Email) {
 		if e.Headers == nil {
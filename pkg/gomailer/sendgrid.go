@@ -53,6 +53,9 @@ func (s *SendGridMailer) Send(e Email) (*types.SendResponse,error) {
 	for _, r := range recipients {
 		p.AddTos(r)
 	}
+	for k, v := range e.CustomArgs {
+		p.SetCustomArg(k, v)
+	}
 	message.AddPersonalizations(p)
 
 	if e.Text != "" {
@@ -88,7 +91,8 @@ func (s *SendGridMailer) Send(e Email) (*types.SendResponse,error) {
 
 	res := &types.SendResponse{
 		Provider: "sendgrid",
-		ProviderID: "",
+		// event webhooks report it as the prefix of sg_message_id
+		ProviderID: resp.Header.Get("X-Message-Id"),
 		Status:     "accepted",
 		RawResponse: bodyBytes,
 		Timestamp:  time.Now(),
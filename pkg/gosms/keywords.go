@@ -0,0 +1,40 @@
+package gosms
+
+import "strings"
+
+// Compliance keywords a recipient can text us. Carriers require senders to
+// stop on STOP, resume on START and answer HELP.
+const (
+	KeywordStop  = "STOP"
+	KeywordStart = "START"
+	KeywordHelp  = "HELP"
+)
+
+// keywords maps every word carriers treat as a compliance keyword to the
+// keyword it means.
+var keywords = map[string]string{
+	"STOP":        KeywordStop,
+	"STOPALL":     KeywordStop,
+	"UNSUBSCRIBE": KeywordStop,
+	"CANCEL":      KeywordStop,
+	"END":         KeywordStop,
+	"QUIT":        KeywordStop,
+	"OPTOUT":      KeywordStop,
+	"REVOKE":      KeywordStop,
+	"START":       KeywordStart,
+	"UNSTOP":      KeywordStart,
+	"YES":         KeywordStart,
+	"OPTIN":       KeywordStart,
+	"HELP":        KeywordHelp,
+	"INFO":        KeywordHelp,
+}
+
+// Keyword returns the compliance keyword an inbound message is, or "" for
+// an ordinary reply. Only a message that is the keyword alone counts, in
+// any case and with surrounding spaces or trailing punctuation, so "stop."
+// opts out but "don't stop" does not.
+func Keyword(body string) string {
+	word := strings.ToUpper(strings.TrimSpace(body))
+	word = strings.TrimRight(word, ".!?")
+	return keywords[word]
+}
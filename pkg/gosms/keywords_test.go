@@ -0,0 +1,23 @@
+package gosms
+
+import "testing"
+
+func TestKeyword(t *testing.T) {
+	cases := map[string]string{
+		"STOP":           KeywordStop,
+		" stop. ":        KeywordStop,
+		"Unsubscribe":    KeywordStop,
+		"start":          KeywordStart,
+		"help?":          KeywordHelp,
+		"INFO":           KeywordHelp,
+		"don't stop":     "",
+		"stop sending":   "",
+		"see you at 5pm": "",
+		"":               "",
+	}
+	for body, want := range cases {
+		if got := Keyword(body); got != want {
+			t.Errorf("Keyword(%q) = %q, want %q", body, got, want)
+		}
+	}
+}
@@ -17,6 +17,10 @@ type TwilioSender struct{
     Timeout         time.Duration     `yaml:"timeout"`
     IdempotencyKey  string            `yaml:"idempotencyKey"`
     Headers         map[string]string `yaml:"headers,omitempty"`
+	// StatusCallback is where Twilio posts delivery receipts, the API's
+	// /api/webhooks/twilio/status. Without it a message counts as delivered
+	// once Twilio accepts it.
+	StatusCallback  string            `yaml:"statusCallback,omitempty"`
 	Client          *twilio.RestClient
 	Ctx context.Context
 }
@@ -40,6 +44,9 @@ func (t *TwilioSender) Send(s SMS) (*types.SendResponse,error) {
 	params.SetBody(s.Text)
 	params.SetFrom(t.FromNumber)
 	params.SetTo(s.To)
+	if t.StatusCallback != "" {
+		params.SetStatusCallback(t.StatusCallback)
+	}
 
 	resp, err := t.Client.Api.CreateMessage(params)
 	if err != nil {
@@ -53,5 +60,9 @@ func (t *TwilioSender) Send(s SMS) (*types.SendResponse,error) {
 		RawResponse: []byte(*resp.Body),
 		Timestamp:  time.Now(),
 	}
+	if t.StatusCallback != "" {
+		// the outcome arrives later, at the status callback
+		res.Status = types.StatusAwaitingReceipt
+	}
 	return res,nil
 }
\ No newline at end of file
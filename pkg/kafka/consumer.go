@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/jsndz/signalbus/metrics"
@@ -12,7 +13,8 @@ import (
 )
 
 type Consumer struct {
-	reader *kafka.Reader
+	reader  *kafka.Reader
+	lagOnce sync.Once
 }
 
 func (c *Consumer) ReadFromKafka(ctx context.Context) (*kafka.Message, error) {
@@ -21,21 +23,28 @@ func (c *Consumer) ReadFromKafka(ctx context.Context) (*kafka.Message, error) {
 		metrics.KafkaSubscriberFailureTotal.WithLabelValues(c.reader.Config().Topic).Inc()
 		return nil, err
 	}
-	go func() {
-		for {
-			if lag, err := c.reader.ReadLag(context.Background()); err == nil {
+	// one reporter per consumer, not per message
+	c.lagOnce.Do(func() {
+		go func() {
+			for {
 				metrics.KafkaConsumerLag.WithLabelValues(
 					c.reader.Config().GroupID,
 					c.reader.Config().Topic,
-				).Set(float64(lag))
+				).Set(float64(c.Lag()))
+				time.Sleep(10 * time.Second)
 			}
-			time.Sleep(10 * time.Second)
-		}
-	}()
+		}()
+	})
 
 	return &m, nil
 }
 
+// Lag is how many messages the consumer is behind on its topic. ReadLag is
+// not available to consumer groups, so it comes from the reader's stats.
+func (c *Consumer) Lag() int64 {
+	return c.reader.Stats().Lag
+}
+
 func (c *Consumer) Close() error {
 	return c.reader.Close()
 }
@@ -0,0 +1,239 @@
+// Package lanes splits each channel's traffic into priority lanes, one Kafka
+// topic per lane, so a large bulk campaign cannot hold up a login code. The
+// normal lane keeps the original topic name:
+//
+//	notification.sms.high
+//	notification.sms
+//	notification.sms.bulk
+//
+// Workers read every lane of their channel through a Reader, which shares
+// throughput between lanes by weight.
+package lanes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jsndz/signalbus/metrics"
+	"github.com/jsndz/signalbus/pkg/kafka"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	High   = "high"
+	Normal = "normal"
+	Bulk   = "bulk"
+)
+
+// All lists the lanes from most to least urgent.
+var All = []string{High, Normal, Bulk}
+
+// Valid reports whether priority names a lane. Empty means Normal.
+func Valid(priority string) bool {
+	switch priority {
+	case "", High, Normal, Bulk:
+		return true
+	}
+	return false
+}
+
+// Validate is Valid with an error for the API to return.
+func Validate(priority string) error {
+	if !Valid(priority) {
+		return fmt.Errorf("priority must be one of %s, %s or %s", High, Normal, Bulk)
+	}
+	return nil
+}
+
+// Topic is the topic carrying channel's notifications of priority.
+func Topic(channel, priority string) string {
+	topic := "notification." + channel
+	switch priority {
+	case High, Bulk:
+		return topic + "." + priority
+	}
+	return topic
+}
+
+// Weights is how many messages each lane may take, relative to the others,
+// while more than one lane has messages waiting. A lane with no weight is
+// only read when the others are idle.
+type Weights map[string]int
+
+// DefaultWeights favour the high lane without starving bulk.
+var DefaultWeights = Weights{High: 8, Normal: 3, Bulk: 1}
+
+// Message is a message read from one of the lanes.
+type Message struct {
+	*kafkago.Message
+	Lane string
+}
+
+type result struct {
+	msg *kafkago.Message
+	err error
+}
+
+type lane struct {
+	name     string
+	consumer *kafka.Consumer
+	in       chan result
+	// head is a message taken from in that has not been handed out yet.
+	head *result
+}
+
+// Reader reads all lanes of a channel.
+type Reader struct {
+	channel string
+	lanes   []*lane
+	sched   *scheduler
+}
+
+// NewReader starts consuming every lane of channel as group. Each lane reads
+// at most one message ahead, and stops when ctx is cancelled.
+func NewReader(ctx context.Context, channel, group string, weights Weights) *Reader {
+	r := &Reader{channel: channel}
+	w := make([]int, len(All))
+	for i, name := range All {
+		l := &lane{
+			name:     name,
+			consumer: kafka.NewConsumerFromEnv(Topic(channel, name), group),
+			in:       make(chan result),
+		}
+		r.lanes = append(r.lanes, l)
+		w[i] = weights[name]
+		go l.read(ctx)
+	}
+	r.sched = newScheduler(w)
+	go r.reportLag(ctx)
+	return r
+}
+
+func (l *lane) read(ctx context.Context) {
+	for {
+		m, err := l.consumer.ReadFromKafka(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case l.in <- result{m, err}:
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// Read returns the next message, choosing among the lanes that have one by
+// weight. It blocks until a lane has a message or ctx is cancelled.
+func (r *Reader) Read(ctx context.Context) (*Message, error) {
+	for {
+		ready := make([]bool, len(r.lanes))
+		found := false
+		for i, l := range r.lanes {
+			if l.head == nil {
+				select {
+				case res := <-l.in:
+					l.head = &res
+				default:
+				}
+			}
+			ready[i] = l.head != nil
+			found = found || ready[i]
+		}
+		if found {
+			l := r.lanes[r.sched.pick(ready)]
+			res := *l.head
+			l.head = nil
+			if res.err != nil {
+				return nil, fmt.Errorf("read %s lane: %w", l.name, res.err)
+			}
+			return &Message{Message: res.msg, Lane: l.name}, nil
+		}
+		if err := r.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait blocks until some lane has a message and parks it as that lane's head.
+func (r *Reader) wait(ctx context.Context) error {
+	// There are three lanes; a select over them avoids reflection.
+	if len(r.lanes) != 3 {
+		return errors.New("lanes: reader expects three lanes")
+	}
+	select {
+	case res := <-r.lanes[0].in:
+		r.lanes[0].head = &res
+	case res := <-r.lanes[1].in:
+		r.lanes[1].head = &res
+	case res := <-r.lanes[2].in:
+		r.lanes[2].head = &res
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (r *Reader) reportLag(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		for _, l := range r.lanes {
+			metrics.NotificationLaneLag.WithLabelValues(r.channel, l.name).Set(float64(l.consumer.Lag()))
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Reader) Close() error {
+	var errs []error
+	for _, l := range r.lanes {
+		errs = append(errs, l.consumer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// scheduler is smooth weighted round robin over the lanes that are ready.
+type scheduler struct {
+	weights []int
+	current []int
+}
+
+func newScheduler(weights []int) *scheduler {
+	return &scheduler{weights: weights, current: make([]int, len(weights))}
+}
+
+// pick returns the index of the ready lane to read next. At least one lane
+// must be ready.
+func (s *scheduler) pick(ready []bool) int {
+	best, total := -1, 0
+	for i, ok := range ready {
+		if !ok || s.weights[i] <= 0 {
+			continue
+		}
+		s.current[i] += s.weights[i]
+		total += s.weights[i]
+		if best < 0 || s.current[i] > s.current[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		// only unweighted lanes have messages; take the most urgent
+		for i, ok := range ready {
+			if ok {
+				return i
+			}
+		}
+	}
+	s.current[best] -= total
+	return best
+}
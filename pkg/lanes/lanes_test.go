@@ -0,0 +1,49 @@
+package lanes
+
+import "testing"
+
+func TestTopic(t *testing.T) {
+	cases := map[string]string{
+		High:   "notification.sms.high",
+		Normal: "notification.sms",
+		"":     "notification.sms",
+		Bulk:   "notification.sms.bulk",
+	}
+	for priority, want := range cases {
+		if got := Topic("sms", priority); got != want {
+			t.Errorf("Topic(sms, %q) = %q, want %q", priority, got, want)
+		}
+	}
+	if Valid("urgent") {
+		t.Error("unknown priority should be invalid")
+	}
+}
+
+func TestSchedulerSharesByWeight(t *testing.T) {
+	s := newScheduler([]int{8, 3, 1})
+	all := []bool{true, true, true}
+	counts := make([]int, 3)
+	for i := 0; i < 120; i++ {
+		counts[s.pick(all)]++
+	}
+	if counts[0] != 80 || counts[1] != 30 || counts[2] != 10 {
+		t.Fatalf("expected 80/30/10, got %v", counts)
+	}
+}
+
+func TestSchedulerSkipsIdleLanes(t *testing.T) {
+	s := newScheduler([]int{8, 3, 1})
+	for i := 0; i < 10; i++ {
+		if got := s.pick([]bool{false, false, true}); got != 2 {
+			t.Fatalf("only bulk is ready, picked %d", got)
+		}
+	}
+	// an unweighted lane is read once the weighted ones are idle
+	s = newScheduler([]int{1, 0, 0})
+	if got := s.pick([]bool{true, true, false}); got != 0 {
+		t.Fatalf("weighted lane should win, picked %d", got)
+	}
+	if got := s.pick([]bool{false, true, true}); got != 1 {
+		t.Fatalf("expected the most urgent unweighted lane, picked %d", got)
+	}
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	ScopeNotifySend        = "notify:send"
+	ScopeNotifyRead        = "notify:read"
+	ScopeTemplatesRead     = "templates:read"
+	ScopeTemplatesWrite    = "templates:write"
+	ScopePoliciesRead      = "policies:read"
+	ScopePoliciesWrite     = "policies:write"
+	ScopePreferencesRead   = "preferences:read"
+	ScopePreferencesWrite  = "preferences:write"
+	ScopeContactsRead      = "contacts:read"
+	ScopeContactsWrite     = "contacts:write"
+	ScopeSuppressionsRead  = "suppressions:read"
+	ScopeSuppressionsWrite = "suppressions:write"
+	ScopeSchemasRead       = "schemas:read"
+	ScopeSchemasWrite      = "schemas:write"
+)
+
+var AllScopes = []string{
+	ScopeNotifySend,
+	ScopeNotifyRead,
+	ScopeTemplatesRead,
+	ScopeTemplatesWrite,
+	ScopePoliciesRead,
+	ScopePoliciesWrite,
+	ScopePreferencesRead,
+	ScopePreferencesWrite,
+	ScopeContactsRead,
+	ScopeContactsWrite,
+	ScopeSuppressionsRead,
+	ScopeSuppressionsWrite,
+	ScopeSchemasRead,
+	ScopeSchemasWrite,
+}
+
+// APIKey only ever stores the SHA-256 of the issued key; the plaintext is
+// returned once when the key is created or rotated.
+type APIKey struct {
+	ID            uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID      uuid.UUID      `gorm:"type:uuid;not null;index"`
+	Name          string         `gorm:"size:100"`
+	Prefix        string         `gorm:"size:16;not null"`
+	Hash          string         `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes        pq.StringArray `gorm:"type:text[];not null"`
+	RotatedFromID *uuid.UUID     `gorm:"type:uuid"`
+	ExpiresAt     *time.Time
+	RevokedAt     *time.Time
+	LastUsedAt    *time.Time
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
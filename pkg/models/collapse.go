@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// CollapseSuppress keeps the first notification of a window and
+	// collapses the ones after it.
+	CollapseSuppress = "suppress"
+	// CollapseReplace holds notifications until the window closes and sends
+	// only the latest.
+	CollapseReplace = "replace"
+
+	// MaxCollapseWindow bounds how long a notification may be held or
+	// remembered for collapsing.
+	MaxCollapseWindow = 24 * time.Hour
+)
+
+// CollapseRule folds repeats of an event for the same user, topic and
+// collapse key into one notification. Window is a Go duration such as
+// "1m"; an empty window turns collapsing off.
+type CollapseRule struct {
+	Window string `gorm:"size:20" json:"window,omitempty"`
+	Mode   string `gorm:"size:20" json:"mode,omitempty"`
+}
+
+func (r CollapseRule) IsZero() bool {
+	return r.Window == ""
+}
+
+// Duration parses Window. It is zero when collapsing is off.
+func (r CollapseRule) Duration() (time.Duration, error) {
+	if r.Window == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(r.Window)
+	if err != nil {
+		return 0, errors.New("window must be a duration such as 30s or 5m")
+	}
+	if d <= 0 || d > MaxCollapseWindow {
+		return 0, fmt.Errorf("window must be positive and at most %s", MaxCollapseWindow)
+	}
+	return d, nil
+}
+
+// ModeOrDefault is Mode, defaulting to CollapseSuppress.
+func (r CollapseRule) ModeOrDefault() string {
+	if r.Mode == "" {
+		return CollapseSuppress
+	}
+	return r.Mode
+}
+
+func (r CollapseRule) Validate() error {
+	if _, err := r.Duration(); err != nil {
+		return err
+	}
+	switch r.Mode {
+	case "", CollapseSuppress, CollapseReplace:
+		return nil
+	}
+	return fmt.Errorf("mode must be %s or %s", CollapseSuppress, CollapseReplace)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollapseRuleValidate(t *testing.T) {
+	valid := []CollapseRule{
+		{},
+		{Window: "1m"},
+		{Window: "30s", Mode: CollapseReplace},
+	}
+	for _, r := range valid {
+		if err := r.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", r, err)
+		}
+	}
+	invalid := []CollapseRule{
+		{Window: "soon"},
+		{Window: "-1m"},
+		{Window: "48h"},
+		{Window: "1m", Mode: "merge"},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", r)
+		}
+	}
+
+	d, err := CollapseRule{Window: "90s"}.Duration()
+	if err != nil || d != 90*time.Second {
+		t.Fatalf("got %v, %v", d, err)
+	}
+	if m := (CollapseRule{Window: "1m"}).ModeOrDefault(); m != CollapseSuppress {
+		t.Fatalf("default mode should be suppress, got %q", m)
+	}
+}
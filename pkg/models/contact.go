@@ -0,0 +1,64 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Contact is where a user can be reached. With a contact on file, notify
+// callers only need to send user_ref.
+type Contact struct {
+	ID         uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID   uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_contact_user,priority:1"`
+	UserRef    string         `gorm:"size:100;not null;uniqueIndex:idx_contact_user,priority:2"`
+	Emails     pq.StringArray `gorm:"type:text[]"`
+	Phones     pq.StringArray `gorm:"type:text[]"`
+	Locale     string         `gorm:"size:100"`
+	Timezone   string         `gorm:"size:64"`
+	Attributes Attributes     `gorm:"type:jsonb"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
+}
+
+// Address returns the first contact point for channel.
+func (c *Contact) Address(channel string) (string, bool) {
+	var points []string
+	switch channel {
+	case "email":
+		points = c.Emails
+	case "sms":
+		points = c.Phones
+	}
+	if len(points) == 0 || points[0] == "" {
+		return "", false
+	}
+	return points[0], true
+}
+
+// Attributes are free-form values a tenant keeps about a contact.
+type Attributes map[string]interface{}
+
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+func (a *Attributes) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	}
+	return errors.New("attributes: unsupported type")
+}
@@ -0,0 +1,36 @@
+package models
+
+import "testing"
+
+func TestContactAddress(t *testing.T) {
+	c := Contact{
+		Emails: []string{"a@example.com", "b@example.com"},
+		Phones: []string{},
+	}
+
+	if got, ok := c.Address("email"); !ok || got != "a@example.com" {
+		t.Errorf("email: got %q, %v", got, ok)
+	}
+	if _, ok := c.Address("sms"); ok {
+		t.Error("sms: expected no contact point")
+	}
+	if _, ok := c.Address("push"); ok {
+		t.Error("push: expected no contact point")
+	}
+}
+
+func TestAttributesRoundTrip(t *testing.T) {
+	in := Attributes{"plan": "pro", "seats": float64(3)}
+	v, err := in.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out Attributes
+	if err := out.Scan(v); err != nil {
+		t.Fatal(err)
+	}
+	if out["plan"] != "pro" || out["seats"] != float64(3) {
+		t.Errorf("got %v", out)
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+// DigestRule turns a topic's email notifications into a periodic digest.
+// Window is hourly or daily, with windows ending on the hour or at midnight
+// UTC. Template names the template the digest is rendered with; it
+// defaults to the topic followed by ".digest".
+type DigestRule struct {
+	Window   string `gorm:"size:20" json:"window,omitempty"`
+	Template string `gorm:"size:100" json:"template,omitempty"`
+}
+
+func (r DigestRule) IsZero() bool {
+	return r.Window == ""
+}
+
+func (r DigestRule) Validate() error {
+	switch r.Window {
+	case "", DigestHourly, DigestDaily:
+		return nil
+	}
+	return fmt.Errorf("window must be %s or %s", DigestHourly, DigestDaily)
+}
+
+// Closes returns the end of the window t falls in.
+func (r DigestRule) Closes(t time.Time) time.Time {
+	t = t.UTC()
+	if r.Window == DigestDaily {
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour).Add(time.Hour)
+}
+
+// TemplateFor is the digest template's name for topic.
+func (r DigestRule) TemplateFor(topic string) string {
+	if r.Template != "" {
+		return r.Template
+	}
+	return topic + ".digest"
+}
+
+// DigestItem is a notification held back for a digest. Items of the same
+// user, topic and channel that fall due together are sent as one digest
+// notification, which DigestID then points at.
+type DigestItem struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID       uuid.UUID `gorm:"type:uuid;not null;index:idx_digest_group,priority:1"`
+	UserRef        string    `gorm:"size:100;not null;index:idx_digest_group,priority:2"`
+	Topic          string    `gorm:"size:100;not null;index:idx_digest_group,priority:3"`
+	Channel        string    `gorm:"size:50;not null;index:idx_digest_group,priority:4"`
+	NotificationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	Locale         string    `gorm:"size:20"`
+	// Recipient is the notification's recipient data; the digest goes to
+	// the most recent one.
+	Recipient Attributes `gorm:"type:jsonb"`
+	// Data is the template data the notification would have been rendered
+	// with.
+	Data      Attributes `gorm:"type:jsonb"`
+	DueAt     time.Time  `gorm:"not null;index:idx_digest_due,priority:2"`
+	DigestID  *uuid.UUID `gorm:"type:uuid;index:idx_digest_due,priority:1"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
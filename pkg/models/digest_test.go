@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestRuleCloses(t *testing.T) {
+	at := time.Date(2025, 3, 1, 14, 25, 0, 0, time.UTC)
+
+	hourly := DigestRule{Window: DigestHourly}
+	if got, want := hourly.Closes(at), time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("hourly: got %v, want %v", got, want)
+	}
+	daily := DigestRule{Window: DigestDaily}
+	if got, want := daily.Closes(at), time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("daily: got %v, want %v", got, want)
+	}
+	// the end of a window belongs to the next one
+	if got, want := hourly.Closes(time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)), time.Date(2025, 3, 1, 16, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("boundary: got %v, want %v", got, want)
+	}
+
+	if err := (DigestRule{Window: "weekly"}).Validate(); err == nil {
+		t.Error("expected weekly to be rejected")
+	}
+	if got := (DigestRule{}).TemplateFor("comment_added"); got != "comment_added.digest" {
+		t.Errorf("default template: got %q", got)
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventSchema is the JSON Schema notify payloads for one event type must
+// match. See package eventschema for its shape.
+type EventSchema struct {
+	ID        uuid.UUID    `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID  uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_event_schema,priority:1"`
+	EventType string       `gorm:"size:100;not null;uniqueIndex:idx_event_schema,priority:2"`
+	Schema    JSONDocument `gorm:"type:jsonb;not null"`
+	CreatedAt time.Time    `gorm:"autoCreateTime"`
+	UpdatedAt time.Time    `gorm:"autoUpdateTime"`
+}
+
+// JSONDocument is JSON kept as text in the database and written as-is,
+// rather than as a quoted string, in API responses.
+type JSONDocument string
+
+func (d JSONDocument) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+func (d *JSONDocument) UnmarshalJSON(b []byte) error {
+	if d == nil {
+		return errors.New("JSONDocument: UnmarshalJSON on nil pointer")
+	}
+	*d = JSONDocument(b)
+	return nil
+}
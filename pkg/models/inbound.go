@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InboundSMS is a text a recipient sent to our number. Inbound messages
+// belong to no tenant: every tenant sends from the same number, so a reply
+// cannot be told apart by tenant.
+type InboundSMS struct {
+	ID                uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Provider          string    `gorm:"size:50;not null;uniqueIndex:idx_inbound_provider_message,priority:1"`
+	ProviderMessageID string    `gorm:"size:100;not null;uniqueIndex:idx_inbound_provider_message,priority:2"`
+	From              string    `gorm:"size:32;not null;index"`
+	To                string    `gorm:"size:32"`
+	Body              string    `gorm:"type:text"`
+	// Keyword is the compliance keyword the body was, see gosms.Keyword.
+	Keyword    string    `gorm:"size:20"`
+	ReceivedAt time.Time `gorm:"autoCreateTime"`
+}
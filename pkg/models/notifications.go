@@ -6,27 +6,73 @@ import (
 	"github.com/google/uuid"
 )
 
+// The composite indexes back NotificationRepository.Search: every query is
+// tenant scoped and ordered by created_at, optionally narrowed by one of
+// status, user_ref or topic. See docs/composite-index.md.
 type Notification struct {
-    ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-    Topic     string    `gorm:"size:100;not null;index"`
+    ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey;index:idx_notifications_tenant_created,priority:3"`
+    TenantID  uuid.UUID `gorm:"type:uuid;not null;index:idx_notifications_tenant_created,priority:1;index:idx_notifications_tenant_status,priority:1;index:idx_notifications_tenant_user,priority:1;index:idx_notifications_tenant_topic,priority:1"`
+    Topic     string    `gorm:"size:100;not null;index;index:idx_notifications_tenant_topic,priority:2"`
     Channel string `gorm:"size:100;not null;index"`
-    UserRef   string    `gorm:"size:100;index"`
-    Status    string    `gorm:"size:50;not null;index"` // pending, delivered, failed
-    CreatedAt time.Time `gorm:"autoCreateTime"`
+    UserRef   string    `gorm:"size:100;index;index:idx_notifications_tenant_user,priority:2"`
+    Status    string    `gorm:"size:50;not null;index;index:idx_notifications_tenant_status,priority:2;index:idx_notifications_due,priority:1"` // scheduled, pending, retrying, sent, delivered, failed, spam_reported, cancelled, suppressed, collapsed, buffered, digested
+    // StatusReason explains statuses that were decided at fan-out, such as
+    // suppressed.
+    StatusReason string `gorm:"size:200" json:",omitempty"`
+    // SendAt is set for scheduled notifications; the scheduler releases
+    // them to the outbox once it has passed.
+    SendAt    *time.Time `gorm:"index:idx_notifications_due,priority:2" json:",omitempty"`
+    // Priority picks the lane the notification travels in: high, bulk, or
+    // empty for normal.
+    Priority string `gorm:"size:20" json:",omitempty"`
+    // RuleOutcome records which policy rule routed the notification.
+    RuleOutcome string `gorm:"size:300" json:",omitempty"`
+    // FallbackOf links a fallback notification to the one that failed. It is
+    // unique so a redelivered fallback event cannot fan out twice.
+    FallbackOf *uuid.UUID `gorm:"type:uuid;uniqueIndex" json:",omitempty"`
+    // CollapseKey groups repeats of an event for the user; CollapsedInto
+    // points a collapsed notification at the one that was kept.
+    CollapseKey   string     `gorm:"size:200;index" json:",omitempty"`
+    CollapsedInto *uuid.UUID `gorm:"type:uuid" json:",omitempty"`
+    // DigestID points a buffered notification at the digest that sent it.
+    DigestID *uuid.UUID `gorm:"type:uuid;index" json:",omitempty"`
+    CreatedAt time.Time `gorm:"autoCreateTime;index:idx_notifications_tenant_created,priority:2;index:idx_notifications_tenant_status,priority:3;index:idx_notifications_tenant_user,priority:3;index:idx_notifications_tenant_topic,priority:3"`
 }
 
 
 type DeliveryAttempt struct {
     ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-    NotificationID uuid.UUID `gorm:"type:uuid;not null;index"`
+    TenantID       uuid.UUID `gorm:"type:uuid;not null;index"`
+    NotificationID uuid.UUID `gorm:"type:uuid;not null;index:idx_attempts_notification_created,priority:1"`
     Channel        string    `gorm:"size:50;not null"`
     Provider       string    `gorm:"size:50;not null"`
     Status         string    `gorm:"size:50;not null"`
     Error          string    `gorm:"type:text"`
     Try            int       `gorm:"not null"`
     LatencyMs      int64     `gorm:"not null"`
+    ProviderMessageID string `gorm:"size:100;index"`
     Message        []byte       
-    CreatedAt      time.Time `gorm:"autoCreateTime"`
+    CreatedAt      time.Time `gorm:"autoCreateTime;index:idx_attempts_notification_created,priority:2"`
 
-    Notification Notification `gorm:"foreignKey:NotificationID;constraint:OnDelete:CASCADE"`
+    Notification Notification `gorm:"foreignKey:NotificationID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// DeliveryEvent is something a provider reported about a message after it
+// accepted it: a delivery, bounce, drop, open, click or spam report. Events
+// are unique per provider event ID, so a redelivered webhook is harmless.
+type DeliveryEvent struct {
+    ID                uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+    TenantID          uuid.UUID `gorm:"type:uuid;not null;index"`
+    NotificationID    uuid.UUID `gorm:"type:uuid;not null;index"`
+    Provider          string    `gorm:"size:50;not null;uniqueIndex:idx_delivery_event,priority:1"`
+    ProviderEventID   string    `gorm:"size:100;not null;uniqueIndex:idx_delivery_event,priority:2"`
+    ProviderMessageID string    `gorm:"size:100"`
+    Event             string    `gorm:"size:50;not null"`
+    // Reason is the provider's explanation of a bounce, drop or deferral.
+    Reason     string    `gorm:"type:text" json:",omitempty"`
+    URL        string    `gorm:"type:text" json:",omitempty"`
+    OccurredAt time.Time `gorm:"not null"`
+    CreatedAt  time.Time `gorm:"autoCreateTime"`
+
+    Notification Notification `gorm:"foreignKey:NotificationID;constraint:OnDelete:CASCADE" json:"-"`
 }
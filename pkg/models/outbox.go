@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage is a Kafka message written in the same transaction as the
+// row it describes and published later by outbox.Relay. Messages for
+// scheduled notifications wait in the scheduled status until the scheduler
+// marks them pending.
+type OutboxMessage struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID       uuid.UUID  `gorm:"type:uuid;not null;index"`
+	NotificationID *uuid.UUID `gorm:"type:uuid;index"`
+	Topic          string     `gorm:"size:200;not null"`
+	Key            []byte
+	Payload        []byte    `gorm:"not null"`
+	Headers        string    `gorm:"type:jsonb"`
+	Status         string    `gorm:"size:20;not null;default:'pending';index:idx_outbox_due,priority:1"` // scheduled, pending, sent, failed, cancelled
+	Attempts       int       `gorm:"not null;default:0"`
+	LastError      string    `gorm:"type:text"`
+	AvailableAt    time.Time `gorm:"not null;index:idx_outbox_due,priority:2"`
+	SentAt         *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserPreference opts a user in or out of notifications. An empty Topic or
+// Channel matches every topic or channel, so one row can express "no SMS at
+// all" or "nothing about marketing".
+type UserPreference struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_preference_unique,priority:1"`
+	UserRef   string    `gorm:"size:100;not null;uniqueIndex:idx_preference_unique,priority:2"`
+	Topic     string    `gorm:"size:100;not null;default:'';uniqueIndex:idx_preference_unique,priority:3"`
+	Channel   string    `gorm:"size:20;not null;default:'';uniqueIndex:idx_preference_unique,priority:4"`
+	OptIn     bool      `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// UserPreferences is every preference stored for one user.
+type UserPreferences []UserPreference
+
+// Allows reports whether the user accepts topic on channel. The most specific
+// preference wins: topic and channel, then topic, then channel, then the
+// user-wide row. Without any matching row the user is opted in.
+func (p UserPreferences) Allows(topic, channel string) bool {
+	best, rank := true, 0
+	for _, pref := range p {
+		r := 0
+		switch {
+		case pref.Topic == topic && pref.Channel == channel:
+			r = 4
+		case pref.Topic == topic && pref.Channel == "":
+			r = 3
+		case pref.Topic == "" && pref.Channel == channel:
+			r = 2
+		case pref.Topic == "" && pref.Channel == "":
+			r = 1
+		}
+		if r > rank {
+			best, rank = pref.OptIn, r
+		}
+	}
+	return best
+}
@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestUserPreferencesAllows(t *testing.T) {
+	prefs := UserPreferences{
+		{Channel: "sms", OptIn: false},
+		{Topic: "otp", Channel: "sms", OptIn: true},
+		{Topic: "marketing", OptIn: false},
+	}
+
+	cases := []struct {
+		topic, channel string
+		want           bool
+	}{
+		{"otp", "sms", true},
+		{"welcome", "sms", false},
+		{"welcome", "email", true},
+		{"marketing", "email", false},
+		{"marketing", "sms", false},
+	}
+	for _, tc := range cases {
+		if got := prefs.Allows(tc.topic, tc.channel); got != tc.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", tc.topic, tc.channel, got, tc.want)
+		}
+	}
+
+	if !(UserPreferences{}).Allows("anything", "email") {
+		t.Errorf("expected users without preferences to be opted in")
+	}
+}
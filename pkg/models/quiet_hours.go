@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuietWindow is a daily do-not-disturb window in the recipient's local
+// time. Start and End are "HH:MM"; a window whose End is before its Start
+// runs over midnight. An empty Timezone means UTC.
+type QuietWindow struct {
+	Start    string `gorm:"size:5" json:"start"`
+	End      string `gorm:"size:5" json:"end"`
+	Timezone string `gorm:"size:64" json:"timezone"`
+}
+
+// QuietHours is a user's own window. It takes precedence over the default
+// window of the policy.
+type QuietHours struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_quiet_hours_user,priority:1"`
+	UserRef     string    `gorm:"size:100;not null;uniqueIndex:idx_quiet_hours_user,priority:2"`
+	QuietWindow `gorm:"embedded"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+func (w QuietWindow) IsZero() bool {
+	return w.Start == "" && w.End == ""
+}
+
+func (w QuietWindow) Validate() error {
+	if w.IsZero() {
+		return nil
+	}
+	if _, err := clockMinutes(w.Start); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	if _, err := clockMinutes(w.End); err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", w.Timezone)
+	}
+	return nil
+}
+
+// DeferUntil reports whether t falls inside the window and, if so, when the
+// window ends.
+func (w QuietWindow) DeferUntil(t time.Time) (time.Time, bool) {
+	if w.IsZero() {
+		return time.Time{}, false
+	}
+	start, err := clockMinutes(w.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := clockMinutes(w.End)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	endOn := func(days int) time.Time {
+		y, m, d := local.Date()
+		return time.Date(y, m, d+days, end/60, end%60, 0, 0, loc)
+	}
+
+	if start < end {
+		if now >= start && now < end {
+			return endOn(0), true
+		}
+		return time.Time{}, false
+	}
+	// the window wraps midnight
+	switch {
+	case now >= start:
+		return endOn(1), true
+	case now < end:
+		return endOn(0), true
+	}
+	return time.Time{}, false
+}
+
+func clockMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, errors.New("must be HH:MM")
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietWindowDeferUntil(t *testing.T) {
+	w := QuietWindow{Start: "22:00", End: "07:00", Timezone: "Asia/Kolkata"}
+	loc, _ := time.LoadLocation("Asia/Kolkata")
+
+	cases := []struct {
+		name   string
+		at     time.Time
+		want   time.Time
+		inside bool
+	}{
+		{"before midnight", time.Date(2025, 3, 1, 23, 30, 0, 0, loc), time.Date(2025, 3, 2, 7, 0, 0, 0, loc), true},
+		{"after midnight", time.Date(2025, 3, 2, 3, 0, 0, 0, loc), time.Date(2025, 3, 2, 7, 0, 0, 0, loc), true},
+		{"daytime", time.Date(2025, 3, 2, 12, 0, 0, 0, loc), time.Time{}, false},
+		{"end is exclusive", time.Date(2025, 3, 2, 7, 0, 0, 0, loc), time.Time{}, false},
+		// 21:00 UTC is 02:30 the next day in Kolkata
+		{"other zone", time.Date(2025, 3, 1, 21, 0, 0, 0, time.UTC), time.Date(2025, 3, 2, 7, 0, 0, 0, loc), true},
+	}
+	for _, tc := range cases {
+		got, ok := w.DeferUntil(tc.at)
+		if ok != tc.inside || !got.Equal(tc.want) {
+			t.Errorf("%s: got (%v, %v), want (%v, %v)", tc.name, got, ok, tc.want, tc.inside)
+		}
+	}
+}
+
+func TestQuietWindowSameDay(t *testing.T) {
+	w := QuietWindow{Start: "12:00", End: "14:00"}
+	at := time.Date(2025, 3, 1, 13, 0, 0, 0, time.UTC)
+	got, ok := w.DeferUntil(at)
+	if !ok || !got.Equal(time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got (%v, %v)", got, ok)
+	}
+	if _, ok := (QuietWindow{}).DeferUntil(at); ok {
+		t.Fatalf("an empty window should never defer")
+	}
+}
+
+func TestQuietWindowValidate(t *testing.T) {
+	if err := (QuietWindow{Start: "22:00", End: "07:00", Timezone: "Europe/Berlin"}).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, w := range []QuietWindow{
+		{Start: "25:00", End: "07:00"},
+		{Start: "22:00", End: "7am"},
+		{Start: "22:00", End: "07:00", Timezone: "Mars/Olympus"},
+	} {
+		if err := w.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", w)
+		}
+	}
+}
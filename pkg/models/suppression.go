@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons an address is suppressed.
+const (
+	SuppressionBounce      = "bounce"
+	SuppressionComplaint   = "complaint"
+	SuppressionUnsubscribe = "unsubscribe"
+	SuppressionManual      = "manual"
+)
+
+var SuppressionReasons = []string{SuppressionBounce, SuppressionComplaint, SuppressionUnsubscribe, SuppressionManual}
+
+func ValidSuppressionReason(reason string) bool {
+	for _, r := range SuppressionReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Suppression stops every send to Address on Channel until it expires, or
+// for good when ExpiresAt is nil. Source says who added it: "api", "import"
+// or the provider that reported the bounce or complaint. A suppression
+// without a tenant (uuid.Nil) is platform-wide: numbers that text STOP to
+// the shared sending number are opted out for every tenant.
+type Suppression struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_suppression_address,priority:1"`
+	Channel   string     `gorm:"size:20;not null;uniqueIndex:idx_suppression_address,priority:2"`
+	Address   string     `gorm:"size:320;not null;uniqueIndex:idx_suppression_address,priority:3"`
+	Reason    string     `gorm:"size:20;not null"`
+	Source    string     `gorm:"size:50"`
+	ExpiresAt *time.Time `gorm:"index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
+}
+
+// Active reports whether the suppression still applies at now.
+func (s *Suppression) Active(now time.Time) bool {
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
+
+// SuppressionAddress is address as suppressions store and match it. Email
+// addresses are compared case-insensitively; phone numbers are expected in
+// E.164 already.
+func SuppressionAddress(channel, address string) string {
+	address = strings.TrimSpace(address)
+	if channel == "email" {
+		return strings.ToLower(address)
+	}
+	return address
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuppressionActive(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	if s := (Suppression{}); !s.Active(now) {
+		t.Error("a suppression without expiry should always apply")
+	}
+	if s := (Suppression{ExpiresAt: &later}); !s.Active(now) {
+		t.Error("a suppression should apply before it expires")
+	}
+	if s := (Suppression{ExpiresAt: &now}); s.Active(now) {
+		t.Error("a suppression should lapse when it expires")
+	}
+}
+
+func TestSuppressionAddress(t *testing.T) {
+	if got := SuppressionAddress("email", " Jane@Example.COM "); got != "jane@example.com" {
+		t.Errorf("email: got %q", got)
+	}
+	if got := SuppressionAddress("sms", "+14155550100"); got != "+14155550100" {
+		t.Errorf("sms: got %q", got)
+	}
+}
@@ -8,13 +8,12 @@ import (
 
 type Template struct {
 	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Name        string    `gorm:"size:100;not null"`
-	Channel     string    `gorm:"type:varchar(20);not null"`
-	ContentType string    `gorm:"type:varchar(20);not null"`
-	Locale      string    `gorm:"size:10;default:'en-US'"`
+	TenantID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_template_unique,priority:1"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex:idx_template_unique,priority:3"`
+	Channel     string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_template_unique,priority:2"`
+	ContentType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_template_unique,priority:5"`
+	Locale      string    `gorm:"size:10;default:'en-US';uniqueIndex:idx_template_unique,priority:4"`
 	Content     string    `gorm:"type:text;not null"` 
 	CreatedAt   time.Time `gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
-
-	_ struct{} `gorm:"uniqueIndex:idx_template_unique,priority:1"`
 }
@@ -7,18 +7,123 @@ import (
 	"github.com/lib/pq"
 )
 
+// Plans a tenant can be on. The plan picks the tenant's API rate limits.
+const (
+	PlanFree       = "free"
+	PlanStandard   = "standard"
+	PlanEnterprise = "enterprise"
+)
+
+var Plans = []string{PlanFree, PlanStandard, PlanEnterprise}
+
+func ValidPlan(plan string) bool {
+	for _, p := range Plans {
+		if p == plan {
+			return true
+		}
+	}
+	return false
+}
+
+type Tenant struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name         string    `gorm:"size:100;not null;uniqueIndex"`
+	Plan         string    `gorm:"size:50;not null;default:standard"`
+	QuotaDaily   int       `gorm:"not null;default:1000"`
+	QuotaMonthly int       `gorm:"not null;default:30000"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+// Policy routes one topic of a tenant; there is at most one per topic.
 type Policy struct {
+	ID       uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_policy_topic,priority:1"`
+	Topic    string         `gorm:"size:100;not null;uniqueIndex:idx_policy_topic,priority:2"`
+	Channels pq.StringArray `gorm:"type:text[];not null"`
+	Locale   string         `gorm:"size:100;not null"`
+	// Transactional topics (receipts, password resets) ignore user opt-outs.
+	Transactional bool `gorm:"not null;default:false"`
+	// Urgent topics (one-time codes, security alerts) ignore quiet hours.
+	Urgent bool `gorm:"not null;default:false"`
+	// QuietHours applies to users without a window of their own.
+	QuietHours QuietWindow `gorm:"embedded;embeddedPrefix:quiet_"`
+	// Rules pick the channels for a request; Channels is the default when
+	// none of them match.
+	Rules []PolicyRule `gorm:"constraint:OnDelete:CASCADE"`
+	// Fallback is the order channels are tried in when delivery on one of
+	// them fails permanently, e.g. sms then email.
+	Fallback pq.StringArray `gorm:"type:text[]"`
+	// Collapse folds repeats of the topic sent with the same collapse key.
+	Collapse CollapseRule `gorm:"embedded;embeddedPrefix:collapse_"`
+	// Digest sends the topic's email as a periodic digest instead.
+	Digest DigestRule `gorm:"embedded;embeddedPrefix:digest_"`
+	// Version counts changes; every version is kept as a PolicyVersion.
+	Version   int             `gorm:"not null;default:1"`
+	Versions  []PolicyVersion `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt time.Time       `gorm:"autoCreateTime"`
+	UpdatedAt time.Time       `gorm:"autoUpdateTime"`
+}
+
+// PolicyVersion is a policy as it stood after one change. Snapshot is the
+// whole policy, rules included, as JSON, and is what a rollback restores.
+type PolicyVersion struct {
+	ID       uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TenantID uuid.UUID      `gorm:"type:uuid;not null"`
+	PolicyID uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_policy_version,priority:1"`
+	Version  int            `gorm:"not null;uniqueIndex:idx_policy_version,priority:2"`
+	Channels pq.StringArray `gorm:"type:text[]"`
+	Snapshot string         `gorm:"type:jsonb;not null" json:"-"`
+	// Change says what happened: created, updated or rolled back to vN.
+	Change string `gorm:"size:100;not null"`
+	// ChangedBy is the API key that made the change.
+	ChangedBy *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
+
+// NextFallback returns the channel to try after channel failed, or "" when
+// the chain ends there or does not include it.
+func (p *Policy) NextFallback(channel string) string {
+	for i, ch := range p.Fallback {
+		if ch == channel && i+1 < len(p.Fallback) {
+			return p.Fallback[i+1]
+		}
+	}
+	return ""
+}
+
+// PolicyRule routes requests whose Condition holds to its own channels and,
+// optionally, a specific provider. Rules are tried by descending Priority,
+// then in the order they were given; the first match wins.
+type PolicyRule struct {
 	ID        uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Topic     string         `gorm:"size:100;not null"`
+	PolicyID  uuid.UUID      `gorm:"type:uuid;not null;index"`
+	Name      string         `gorm:"size:100"`
+	Condition string         `gorm:"type:text;not null"`
 	Channels  pq.StringArray `gorm:"type:text[];not null"`
-	Locale    string         `gorm:"size:100;not null"`
-	CreatedAt time.Time      `gorm:"autoCreateTime"`
+	Provider  string         `gorm:"size:50"`
+	Priority  int            `gorm:"not null;default:0"`
+	Position  int            `gorm:"not null;default:0"`
 }
 
+// IdempotencyKey is the durable copy of a response replayed by the
+// idempotency middleware. Rows older than the configured TTL are ignored and
+// eventually removed by the sweeper.
 type IdempotencyKey struct {
+	TenantID    uuid.UUID `gorm:"type:uuid;primaryKey"`
 	Key         string    `gorm:"primaryKey;size:64;"`
 	RequestHash string    `gorm:"not null"`
 	Response    string    `gorm:"type:jsonb"`
 	StatusCode  int       `gorm:"not null"`
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index"`
+}
+
+// IdempotencyLock reserves an idempotency key in Postgres while its request
+// runs, standing in for the Redis lock when Redis is unavailable. A lock
+// past ExpiresAt is taken over by the next request.
+type IdempotencyLock struct {
+	TenantID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Key       string    `gorm:"primaryKey;size:64"`
+	Token     string    `gorm:"size:36;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "testing"
+
+func TestPolicyNextFallback(t *testing.T) {
+	p := Policy{Fallback: []string{"sms", "email"}}
+
+	if got := p.NextFallback("sms"); got != "email" {
+		t.Errorf("after sms: got %q, want email", got)
+	}
+	if got := p.NextFallback("email"); got != "" {
+		t.Errorf("after email: got %q, want end of chain", got)
+	}
+	if got := p.NextFallback("push"); got != "" {
+		t.Errorf("channel outside the chain: got %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageDay is the usage ledger: what a tenant sent on one channel on one
+// UTC day. Accepted counts notifications the API queued; Delivered and
+// Failed come from the workers' final delivery attempts.
+type UsageDay struct {
+	TenantID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Channel   string    `gorm:"size:50;primaryKey"`
+	Day       time.Time `gorm:"type:date;primaryKey"`
+	Accepted  int64     `gorm:"not null;default:0"`
+	Delivered int64     `gorm:"not null;default:0"`
+	Failed    int64     `gorm:"not null;default:0"`
+}
+
+// ChannelQuota caps what a tenant may send on one channel per calendar
+// month (UTC), on top of the tenant's own QuotaMonthly.
+type ChannelQuota struct {
+	TenantID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Channel   string    `gorm:"size:50;primaryKey"`
+	Monthly   int64     `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// UsageDate is the ledger day t falls on.
+func UsageDate(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// MonthStart is the first day of the month t falls in.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsagePeriods(t *testing.T) {
+	// late evening in New York is already the next day in UTC
+	loc := time.FixedZone("EST", -5*60*60)
+	at := time.Date(2025, 1, 31, 21, 30, 0, 0, loc)
+
+	if got, want := UsageDate(at), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("usage date: got %v, want %v", got, want)
+	}
+	if got, want := MonthStart(at), time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("month start: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jsndz/signalbus/pkg/lanes"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// NewMessage builds the outbox record that hands n to its channel worker.
+// It is the one place that decides which topic a notification goes to: the
+// lane of n's channel that matches its priority.
+func NewMessage(n *models.Notification, key string, msg types.KafkaStreamData, headers string) (*models.OutboxMessage, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal kafka message: %w", err)
+	}
+	id := n.ID
+	return &models.OutboxMessage{
+		TenantID:       n.TenantID,
+		NotificationID: &id,
+		Topic:          lanes.Topic(n.Channel, n.Priority),
+		Key:            []byte(key),
+		Payload:        payload,
+		Headers:        headers,
+		AvailableAt:    time.Now(),
+	}, nil
+}
+
+// TraceHeaders captures the caller's trace context so the relay can continue
+// the same trace when it publishes.
+func TraceHeaders(ctx context.Context) (string, error) {
+	carrier := make(map[string]string)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(carrier))
+	b, err := json.Marshal(carrier)
+	if err != nil {
+		return "", fmt.Errorf("marshal trace headers: %w", err)
+	}
+	return string(b), nil
+}
+
+// NewInboundMessage builds the outbox record that publishes in to
+// types.InboundSMSTopic, keyed by the sender so one sender's texts stay in
+// order.
+func NewInboundMessage(in *models.InboundSMS, headers string) (*models.OutboxMessage, error) {
+	payload, err := json.Marshal(types.InboundSMS{
+		ID:                in.ID,
+		Provider:          in.Provider,
+		ProviderMessageID: in.ProviderMessageID,
+		From:              in.From,
+		To:                in.To,
+		Body:              in.Body,
+		Keyword:           in.Keyword,
+		ReceivedAt:        in.ReceivedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal inbound sms: %w", err)
+	}
+	return &models.OutboxMessage{
+		Topic:       types.InboundSMSTopic,
+		Key:         []byte(in.From),
+		Payload:     payload,
+		Headers:     headers,
+		AvailableAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,154 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jsndz/signalbus/metrics"
+	"github.com/jsndz/signalbus/pkg/kafka"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Config struct {
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	MaxBackoff   time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:    100,
+		PollInterval: 500 * time.Millisecond,
+		MaxAttempts:  20,
+		MaxBackoff:   5 * time.Minute,
+	}
+}
+
+// Relay publishes outbox rows to Kafka. Several relays may run against the
+// same table; each batch is claimed with FOR UPDATE SKIP LOCKED, so a row is
+// published by one relay at a time and at least once overall.
+type Relay struct {
+	repo     *repositories.OutboxRepository
+	producer *kafka.Producer
+	logger   *zap.Logger
+	cfg      Config
+}
+
+func NewRelay(db *gorm.DB, producer *kafka.Producer, logger *zap.Logger, cfg Config) *Relay {
+	return &Relay{
+		repo:     repositories.NewOutboxRepository(db),
+		producer: producer,
+		logger:   logger,
+		cfg:      cfg,
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	r.logger.Info("Outbox relay started", zap.Duration("poll_interval", r.cfg.PollInterval))
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Outbox relay stopped")
+			return
+		case <-ticker.C:
+			// drain the backlog before waiting for the next tick
+			for {
+				n, err := r.relayBatch(ctx)
+				if err != nil {
+					r.logger.Error("outbox relay batch failed", zap.Error(err))
+					break
+				}
+				if n < r.cfg.BatchSize {
+					break
+				}
+			}
+			if pending, err := r.repo.CountPending(); err == nil {
+				metrics.OutboxPending.Set(float64(pending))
+			}
+		}
+	}
+}
+
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	var claimed int
+	err := r.repo.Transaction(func(tx *repositories.OutboxRepository) error {
+		msgs, err := tx.LockDue(time.Now(), r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		claimed = len(msgs)
+		for i := range msgs {
+			if err := r.publish(ctx, tx, &msgs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// publish sends one message and records the outcome. The returned error is
+// only for database failures; Kafka failures are recorded for retry.
+func (r *Relay) publish(ctx context.Context, tx *repositories.OutboxRepository, msg *models.OutboxMessage) error {
+	msgCtx := ctx
+	if msg.Headers != "" {
+		carrier := make(map[string]string)
+		if err := json.Unmarshal([]byte(msg.Headers), &carrier); err == nil {
+			msgCtx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+		}
+	}
+
+	err := r.producer.Publish(msgCtx, msg.Topic, msg.Key, msg.Payload)
+	if err == nil {
+		metrics.OutboxPublishedTotal.WithLabelValues(msg.Topic, "sent").Inc()
+		return tx.MarkSent(msg.ID, time.Now())
+	}
+
+	attempts := msg.Attempts + 1
+	if attempts >= r.cfg.MaxAttempts {
+		metrics.OutboxPublishedTotal.WithLabelValues(msg.Topic, "failed").Inc()
+		r.logger.Error("outbox message failed permanently",
+			zap.String("outbox_id", msg.ID.String()),
+			zap.String("topic", msg.Topic),
+			zap.Int("attempts", attempts),
+			zap.Error(err),
+		)
+		return tx.MarkFailed(msg, attempts, err.Error())
+	}
+
+	metrics.OutboxPublishedTotal.WithLabelValues(msg.Topic, "retry").Inc()
+	wait := Backoff(attempts, r.cfg.MaxBackoff)
+	r.logger.Warn("outbox publish failed, will retry",
+		zap.String("outbox_id", msg.ID.String()),
+		zap.String("topic", msg.Topic),
+		zap.Int("attempt", attempts),
+		zap.Duration("retry_in", wait),
+		zap.Error(err),
+	)
+	return tx.MarkRetry(msg.ID, attempts, err.Error(), time.Now().Add(wait))
+}
+
+// Backoff doubles from one second per failed attempt, capped at max.
+func Backoff(attempts int, max time.Duration) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 30 {
+		return max
+	}
+	d := time.Second << (attempts - 1)
+	if d > max {
+		return max
+	}
+	return d
+}
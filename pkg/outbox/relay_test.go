@@ -0,0 +1,25 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDoublesUntilCap(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{9, time.Minute},
+		{64, time.Minute},
+	}
+	for _, tc := range cases {
+		if got := Backoff(tc.attempts, time.Minute); got != tc.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tc.attempts, got, tc.want)
+		}
+	}
+}
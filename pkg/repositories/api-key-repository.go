@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *APIKeyRepository) GetByID(id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetActiveByHash returns the key matching hash if it is neither revoked nor
+// past its expiry at the given time.
+func (r *APIKeyRepository) GetActiveByHash(hash string, now time.Time) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.
+		Where("hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", hash, now).
+		First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) ListByTenant(tenantID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.db.Where("tenant_id = ?", tenantID).
+		Order("created_at").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Revoke(id uuid.UUID, at time.Time) error {
+	res := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Rotate stores next and shortens the lifetime of old to oldExpiresAt in a
+// single transaction, so both keys are valid during the overlap.
+func (r *APIKeyRepository) Rotate(old *models.APIKey, next *models.APIKey, oldExpiresAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.APIKey{}).
+			Where("id = ?", old.ID).
+			Update("expires_at", oldExpiresAt).Error
+	})
+}
+
+func (r *APIKeyRepository) TouchLastUsed(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", at).Error
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ContactRepository struct {
+	db *gorm.DB
+}
+
+func NewContactRepository(db *gorm.DB) *ContactRepository {
+	return &ContactRepository{db: db}
+}
+
+// Upsert stores the contact, replacing every field of an existing one for
+// the same user.
+func (r *ContactRepository) Upsert(c *models.Contact) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "user_ref"}},
+		DoUpdates: clause.AssignmentColumns([]string{"emails", "phones", "locale", "timezone", "attributes", "updated_at"}),
+	}).Create(c).Error
+}
+
+func (r *ContactRepository) GetByUser(tenantID uuid.UUID, userRef string) (*models.Contact, error) {
+	var c models.Contact
+	if err := r.db.First(&c, "tenant_id = ? AND user_ref = ?", tenantID, userRef).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *ContactRepository) Delete(tenantID uuid.UUID, userRef string) error {
+	return r.db.Delete(&models.Contact{}, "tenant_id = ? AND user_ref = ?", tenantID, userRef).Error
+}
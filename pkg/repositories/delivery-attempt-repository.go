@@ -18,33 +18,34 @@ func (r *DeliveryAttemptRepository) Create(attempt *models.DeliveryAttempt) erro
     return r.db.Create(attempt).Error
 }
 
-func (r *DeliveryAttemptRepository) GetByID(id uuid.UUID) (*models.DeliveryAttempt, error) {
+func (r *DeliveryAttemptRepository) GetByID(tenantID, id uuid.UUID) (*models.DeliveryAttempt, error) {
     var attempt models.DeliveryAttempt
-    if err := r.db.First(&attempt, "id = ?", id).Error; err != nil {
+    if err := r.db.First(&attempt, "tenant_id = ? AND id = ?", tenantID, id).Error; err != nil {
         return nil, err
     }
     return &attempt, nil
 }
 
-func (r *DeliveryAttemptRepository) GetDLQByNotificationID(id uuid.UUID) (*models.DeliveryAttempt, error) {
+func (r *DeliveryAttemptRepository) GetDLQByNotificationID(tenantID, id uuid.UUID) (*models.DeliveryAttempt, error) {
     var attempt models.DeliveryAttempt
     if err := r.db.
-        Where("notification_id = ? AND status = ?", id, "dlq").
+        Where("tenant_id = ? AND notification_id = ? AND status = ?", tenantID, id, "dlq").
         First(&attempt).Error; err != nil {
         return nil, err
     }
     return &attempt, nil
 }
 
-func (r *DeliveryAttemptRepository) ListByNotification(notificationID uuid.UUID) ([]models.DeliveryAttempt, error) {
+func (r *DeliveryAttemptRepository) ListByNotification(tenantID, notificationID uuid.UUID) ([]models.DeliveryAttempt, error) {
     var attempts []models.DeliveryAttempt
-    if err := r.db.Where("notification_id = ?", notificationID).
+    if err := r.db.Where("tenant_id = ? AND notification_id = ?", tenantID, notificationID).
+        Order("created_at, try").
         Find(&attempts).Error; err != nil {
         return nil, err
     }
     return attempts, nil
 }
 
-func (r *DeliveryAttemptRepository) Delete(id uuid.UUID) error {
-    return r.db.Delete(&models.DeliveryAttempt{}, "id = ?", id).Error
+func (r *DeliveryAttemptRepository) Delete(tenantID, id uuid.UUID) error {
+    return r.db.Delete(&models.DeliveryAttempt{}, "tenant_id = ? AND id = ?", tenantID, id).Error
 }
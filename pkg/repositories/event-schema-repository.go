@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type EventSchemaRepository struct {
+	db *gorm.DB
+}
+
+func NewEventSchemaRepository(db *gorm.DB) *EventSchemaRepository {
+	return &EventSchemaRepository{db: db}
+}
+
+func (r *EventSchemaRepository) Create(s *models.EventSchema) error {
+	return r.db.Create(s).Error
+}
+
+func (r *EventSchemaRepository) GetByEventType(tenantID uuid.UUID, eventType string) (*models.EventSchema, error) {
+	var s models.EventSchema
+	if err := r.db.First(&s, "tenant_id = ? AND event_type = ?", tenantID, eventType).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *EventSchemaRepository) List(tenantID uuid.UUID) ([]models.EventSchema, error) {
+	var schemas []models.EventSchema
+	if err := r.db.Where("tenant_id = ?", tenantID).Order("event_type").Find(&schemas).Error; err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+// UpdateSchema replaces the schema document. It returns
+// gorm.ErrRecordNotFound when there is no schema for the event type.
+func (r *EventSchemaRepository) UpdateSchema(tenantID uuid.UUID, eventType string, schema models.JSONDocument) error {
+	res := r.db.Model(&models.EventSchema{}).
+		Where("tenant_id = ? AND event_type = ?", tenantID, eventType).
+		Update("schema", schema)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *EventSchemaRepository) Delete(tenantID uuid.UUID, eventType string) error {
+	return r.db.Delete(&models.EventSchema{}, "tenant_id = ? AND event_type = ?", tenantID, eventType).Error
+}
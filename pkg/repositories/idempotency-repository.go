@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Get returns the record for key if it was stored after notBefore.
+func (r *IdempotencyRepository) Get(tenantID uuid.UUID, key string, notBefore time.Time) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.
+		Where("tenant_id = ? AND key = ? AND created_at > ?", tenantID, key, notBefore).
+		First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Save stores record, replacing an expired row the sweeper has not removed
+// yet.
+func (r *IdempotencyRepository) Save(record *models.IdempotencyKey) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"request_hash", "response", "status_code", "created_at"}),
+	}).Create(record).Error
+}
+
+// DeleteExpired removes records created before cutoff and returns how many
+// were deleted.
+func (r *IdempotencyRepository) DeleteExpired(cutoff time.Time) (int64, error) {
+	res := r.db.Where("created_at < ?", cutoff).Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
+
+// Reserve takes key for token until expiresAt, taking over a reservation
+// that expired before now. It reports false while another token holds key.
+func (r *IdempotencyRepository) Reserve(tenantID uuid.UUID, key, token string, now, expiresAt time.Time) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "key"}},
+		Where:     clause.Where{Exprs: []clause.Expression{gorm.Expr("idempotency_locks.expires_at <= ?", now)}},
+		DoUpdates: clause.AssignmentColumns([]string{"token", "expires_at"}),
+	}).Create(&models.IdempotencyLock{
+		TenantID:  tenantID,
+		Key:       key,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Release ends token's reservation of key. A reservation taken over by
+// another request is left alone.
+func (r *IdempotencyRepository) Release(tenantID uuid.UUID, key, token string) error {
+	return r.db.Delete(&models.IdempotencyLock{}, "tenant_id = ? AND key = ? AND token = ?", tenantID, key, token).Error
+}
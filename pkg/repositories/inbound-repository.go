@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InboundRepository struct {
+	db *gorm.DB
+}
+
+func NewInboundRepository(db *gorm.DB) *InboundRepository {
+	return &InboundRepository{db: db}
+}
+
+// Transaction runs fn with a repository bound to a single transaction.
+func (r *InboundRepository) Transaction(fn func(tx *InboundRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&InboundRepository{db: tx})
+	})
+}
+
+// Create stores msg unless the provider delivered it before. It reports
+// whether msg was new.
+func (r *InboundRepository) Create(msg *models.InboundSMS) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(msg)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *InboundRepository) CreateOutbox(msg *models.OutboxMessage) error {
+	return r.db.Create(msg).Error
+}
+
+// Suppressions is the suppression list in r's transaction, so an opt-out
+// commits with the message that asked for it.
+func (r *InboundRepository) Suppressions() *SuppressionRepository {
+	return &SuppressionRepository{db: r.db}
+}
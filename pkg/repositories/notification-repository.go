@@ -1,9 +1,16 @@
 package repositories
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/types"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type NotificationRepository struct {
@@ -15,62 +22,564 @@ func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
 }
 
 
+// Transaction runs fn with a repository bound to a single transaction.
+func (r *NotificationRepository) Transaction(fn func(tx *NotificationRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&NotificationRepository{db: tx})
+	})
+}
+
+func (r *NotificationRepository) CreateOutbox(msg *models.OutboxMessage) error {
+	return r.db.Create(msg).Error
+}
+
+// heldStatuses are notifications waiting for their SendAt; their outbox
+// messages sit in the scheduled status until the scheduler releases them.
+var heldStatuses = []string{"scheduled", "deferred"}
+
+// LockDueScheduled claims scheduled and deferred notifications whose SendAt
+// has passed.
+// Rows held by another scheduler are skipped, so replicas split the work.
+func (r *NotificationRepository) LockDueScheduled(now time.Time, limit int) ([]models.Notification, error) {
+	var notifications []models.Notification
+	if err := r.db.
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status IN ? AND send_at <= ?", heldStatuses, now).
+		Order("send_at").
+		Limit(limit).
+		Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// ReleaseScheduled moves scheduled and deferred notifications and their outbox messages
+// to pending so the relay publishes them.
+func (r *NotificationRepository) ReleaseScheduled(ids []uuid.UUID, now time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.Model(&models.OutboxMessage{}).
+		Where("notification_id IN ? AND status = ?", ids, "scheduled").
+		Updates(map[string]interface{}{"status": "pending", "available_at": now}).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&models.Notification{}).
+		Where("id IN ? AND status IN ?", ids, heldStatuses).
+		Update("status", "pending").Error
+}
+
+// Defer holds a notification no worker has finished with until until. A
+// message redelivered after a worker restart finds it retrying, or already
+// deferred, and is held all the same. It reports false when the
+// notification was cancelled, delivered or given up on.
+func (r *NotificationRepository) Defer(tenantID, id uuid.UUID, until time.Time, reason string) (bool, error) {
+	res := r.db.Model(&models.Notification{}).
+		Where("tenant_id = ? AND id = ? AND status IN ?", tenantID, id, cancellableStatuses).
+		Updates(map[string]interface{}{
+			"status":        "deferred",
+			"send_at":       until,
+			"status_reason": reason,
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// RescheduleHeld moves the notification's held outbox messages to until. It
+// reports false when there are none.
+func (r *NotificationRepository) RescheduleHeld(tenantID, id uuid.UUID, until time.Time) (bool, error) {
+	res := r.db.Model(&models.OutboxMessage{}).
+		Where("tenant_id = ? AND notification_id = ? AND status = ?", tenantID, id, "scheduled").
+		Update("available_at", until)
+	return res.RowsAffected > 0, res.Error
+}
+
+// cancellableStatuses are notifications no worker has finished with yet.
+var cancellableStatuses = []string{"pending", "scheduled", "deferred", "retrying"}
+
+// Cancel stops a notification that has not been delivered or given up on,
+// along with its outbox messages that have not been published. A worker
+// that already holds the message sees the status before its next attempt.
+// It reports false when the notification exists but is past cancelling.
+func (r *NotificationRepository) Cancel(tenantID, id uuid.UUID) (bool, error) {
+	res := r.db.Model(&models.Notification{}).
+		Where("tenant_id = ? AND id = ? AND status IN ?", tenantID, id, cancellableStatuses).
+		Update("status", "cancelled")
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		if _, err := r.GetByID(tenantID, id); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, r.db.Model(&models.OutboxMessage{}).
+		Where("notification_id = ? AND status IN ?", id, []string{"pending", "scheduled"}).
+		Update("status", "cancelled").Error
+}
+
+// Cancelled reports whether the notification was cancelled.
+func (r *NotificationRepository) Cancelled(tenantID, id uuid.UUID) (bool, error) {
+	var n int64
+	err := r.db.Model(&models.Notification{}).
+		Where("tenant_id = ? AND id = ? AND status = ?", tenantID, id, "cancelled").
+		Count(&n).Error
+	return n > 0, err
+}
+
+// MarkRetrying records that a worker is retrying the notification. A
+// notification cancelled in the meantime stays cancelled.
+func (r *NotificationRepository) MarkRetrying(tenantID, id uuid.UUID) error {
+	return r.db.Model(&models.Notification{}).
+		Where("tenant_id = ? AND id = ? AND status = ?", tenantID, id, "pending").
+		Update("status", "retrying").Error
+}
+
+// MarkFailed records that a worker gave up on the notification. It reports
+// false when the notification was cancelled while the worker backed off.
+func (r *NotificationRepository) MarkFailed(tenantID, id uuid.UUID) (bool, error) {
+	res := r.db.Model(&models.Notification{}).
+		Where("tenant_id = ? AND id = ? AND status <> ?", tenantID, id, "cancelled").
+		Update("status", "failed")
+	return res.RowsAffected > 0, res.Error
+}
+
+// MarkSuppressed records that a worker dropped the notification because its
+// recipient is on the suppression list.
+func (r *NotificationRepository) MarkSuppressed(tenantID, id uuid.UUID, reason string) error {
+	return r.db.Model(&models.Notification{}).
+		Where("tenant_id = ? AND id = ?", tenantID, id).
+		Updates(map[string]interface{}{"status": "suppressed", "status_reason": reason}).Error
+}
+
+// CollapseHeld collapses the notifications of n's collapse group that are
+// still held into n, and cancels their outbox messages.
+func (r *NotificationRepository) CollapseHeld(n *models.Notification) error {
+	var ids []uuid.UUID
+	if err := r.db.Model(&models.Notification{}).
+		Where("tenant_id = ? AND user_ref = ? AND topic = ? AND channel = ? AND collapse_key = ?",
+			n.TenantID, n.UserRef, n.Topic, n.Channel, n.CollapseKey).
+		Where("status IN ? AND id <> ?", heldStatuses, n.ID).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.Model(&models.Notification{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"status":         "collapsed",
+			"status_reason":  "replaced by a later notification",
+			"collapsed_into": n.ID,
+		}).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&models.OutboxMessage{}).
+		Where("notification_id IN ? AND status = ?", ids, "scheduled").
+		Update("status", "cancelled").Error
+}
+
+func (r *NotificationRepository) CreateDigestItem(item *models.DigestItem) error {
+	return r.db.Create(item).Error
+}
+
+// LockDueDigest claims the items of one digest that is due: the pending
+// items of a user, topic and channel whose window has closed, oldest first.
+// It returns none when nothing is due. Groups held by another job are
+// skipped.
+func (r *NotificationRepository) LockDueDigest(now time.Time) ([]models.DigestItem, error) {
+	var first models.DigestItem
+	err := r.db.
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("digest_id IS NULL AND due_at <= ?", now).
+		Order("due_at").
+		Limit(1).
+		Find(&first).Error
+	if err != nil || first.ID == uuid.Nil {
+		return nil, err
+	}
+	var items []models.DigestItem
+	err = r.db.
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("tenant_id = ? AND user_ref = ? AND topic = ? AND channel = ?",
+			first.TenantID, first.UserRef, first.Topic, first.Channel).
+		Where("digest_id IS NULL AND due_at <= ?", now).
+		Order("created_at").
+		Find(&items).Error
+	return items, err
+}
+
+// MarkDigested links items and their notifications to the digest
+// notification that carried them.
+func (r *NotificationRepository) MarkDigested(items []models.DigestItem, digestID uuid.UUID) error {
+	itemIDs := make([]uuid.UUID, len(items))
+	notificationIDs := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		itemIDs[i] = item.ID
+		notificationIDs[i] = item.NotificationID
+	}
+	if err := r.db.Model(&models.DigestItem{}).
+		Where("id IN ?", itemIDs).
+		Update("digest_id", digestID).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&models.Notification{}).
+		Where("id IN ?", notificationIDs).
+		Updates(map[string]interface{}{"status": "digested", "digest_id": digestID}).Error
+}
+
+// LatestOutbox returns the most recent outbox message for a notification,
+// which holds the payload its worker received.
+func (r *NotificationRepository) LatestOutbox(tenantID, notificationID uuid.UUID) (*models.OutboxMessage, error) {
+	var msg models.OutboxMessage
+	if err := r.db.
+		Where("tenant_id = ? AND notification_id = ?", tenantID, notificationID).
+		Order("created_at DESC").
+		First(&msg).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// CreateFallback stores n unless a fallback for the same notification
+// already exists, and reports whether it was created.
+func (r *NotificationRepository) CreateFallback(n *models.Notification) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "fallback_of"}},
+		DoNothing: true,
+	}).Create(n)
+	return res.RowsAffected > 0, res.Error
+}
+
 func (r *NotificationRepository) Create(notification *models.Notification) error {
 	return r.db.Create(notification).Error
 }
 
-func (r *NotificationRepository) GetByID(id uuid.UUID) (*models.Notification, error) {
+func (r *NotificationRepository) GetByID(tenantID, id uuid.UUID) (*models.Notification, error) {
 	var notification models.Notification
-	if err := r.db.First(&notification, "id = ?", id).Error; err != nil {
+	if err := r.db.First(&notification, "tenant_id = ? AND id = ?", tenantID, id).Error; err != nil {
 		return nil, err
 	}
 	return &notification, nil
 }
 
-func (r *NotificationRepository) List() ([]models.Notification, error) {
+func (r *NotificationRepository) List(tenantID uuid.UUID) ([]models.Notification, error) {
 	var notifications []models.Notification
-	if err := r.db.Find(&notifications).Error; err != nil {
+	if err := r.db.Where("tenant_id = ?", tenantID).Find(&notifications).Error; err != nil {
 		return nil, err
 	}
 	return notifications, nil
 }
 
-func (r *NotificationRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.Notification{}, "id = ?", id).Error
+// NotificationFilter narrows Search. Zero values are ignored.
+type NotificationFilter struct {
+	Topic         string
+	Channel       string
+	Status        string
+	UserRef       string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Ascending     bool
+	After         *NotificationCursor
+	Limit         int
+}
+
+// NotificationCursor marks the last row of a page. It is handed to clients
+// as an opaque string by Encode.
+type NotificationCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
 }
 
-func (r *NotificationRepository) UpdateStatus(id uuid.UUID, status string) error {
+func (c NotificationCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeNotificationCursor(s string) (*NotificationCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c NotificationCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+// Search returns one page of the tenant's notifications ordered by
+// (created_at, id) using keyset pagination.
+func (r *NotificationRepository) Search(tenantID uuid.UUID, f NotificationFilter) ([]models.Notification, error) {
+	q := r.db.Where("tenant_id = ?", tenantID)
+	if f.Topic != "" {
+		q = q.Where("topic = ?", f.Topic)
+	}
+	if f.Channel != "" {
+		q = q.Where("channel = ?", f.Channel)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.UserRef != "" {
+		q = q.Where("user_ref = ?", f.UserRef)
+	}
+	if f.CreatedAfter != nil {
+		q = q.Where("created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		q = q.Where("created_at < ?", *f.CreatedBefore)
+	}
+
+	order := "created_at DESC, id DESC"
+	if f.Ascending {
+		order = "created_at ASC, id ASC"
+		if f.After != nil {
+			q = q.Where("(created_at, id) > (?, ?)", f.After.CreatedAt, f.After.ID)
+		}
+	} else if f.After != nil {
+		q = q.Where("(created_at, id) < (?, ?)", f.After.CreatedAt, f.After.ID)
+	}
+
+	var notifications []models.Notification
+	if err := q.Order(order).Limit(f.Limit).Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *NotificationRepository) Delete(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.Notification{}, "tenant_id = ? AND id = ?", tenantID, id).Error
+}
+
+func (r *NotificationRepository) UpdateStatus(tenantID, id uuid.UUID, status string) error {
 	return r.db.Model(&models.Notification{}).
-		Where("id = ?", id).
+		Where("tenant_id = ? AND id = ?", tenantID, id).
 		Update("status", status).Error
 }
 
+// NewDLQAttempt is the attempt a worker records when it gives up on msg. It
+// keeps the consumed message, which is what a redrive republishes.
+func NewDLQAttempt(msg types.KafkaStreamData, channel, provider string) (*models.DeliveryAttempt, error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DeliveryAttempt{
+		TenantID:       msg.TenantID,
+		NotificationID: msg.NotificationId,
+		Channel:        channel,
+		Provider:       provider,
+		Status:         "dlq",
+		Message:        b,
+	}, nil
+}
+
+// CreateAttempt stores a delivery attempt. A worker's final attempt,
+// delivered or dead-lettered, is also counted in the usage ledger.
 func (r *NotificationRepository) CreateAttempt(attempt *models.DeliveryAttempt) error {
-	return r.db.Create(attempt).Error
+	delta := models.UsageDay{TenantID: attempt.TenantID, Channel: attempt.Channel, Day: time.Now()}
+	switch attempt.Status {
+	case "delivered":
+		delta.Delivered = 1
+	case "dlq":
+		delta.Failed = 1
+	default:
+		return r.db.Create(attempt).Error
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+		return recordUsage(tx, delta)
+	})
+}
+
+// Receipt is a provider's report on a message after the worker handed it
+// over, found by the provider's message ID.
+type Receipt struct {
+	Provider          string
+	ProviderMessageID string
+	// Status is the provider's own status, stored on the attempt.
+	Status string
+	Error  string
+	// Outcome is what the notification becomes, delivered or failed, or ""
+	// when the report is not final.
+	Outcome string
+}
+
+// receiptPredecessors are the statuses a receipt may move a notification
+// out of. A worker marks a message delivered when the provider accepts it,
+// so a carrier failure or spam report can still overturn that.
+var receiptPredecessors = map[string][]string{
+	"delivered":     {"pending", "retrying", "sent"},
+	"failed":        {"pending", "retrying", "sent", "delivered"},
+	"spam_reported": {"pending", "retrying", "sent", "delivered"},
+}
+
+// FindSent returns the first attempt that carries the provider's message ID.
+func (r *NotificationRepository) FindSent(provider, providerMessageID string) (*models.DeliveryAttempt, error) {
+	var attempt models.DeliveryAttempt
+	if err := r.db.Order("created_at").
+		First(&attempt, "provider = ? AND provider_message_id = ?", provider, providerMessageID).Error; err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// settle moves a notification to the outcome a provider reported and keeps
+// the usage ledger in step: a bounce after the worker counted a delivery
+// turns that delivery into a failure.
+func settle(tx *gorm.DB, tenantID, notificationID uuid.UUID, channel, outcome string) error {
+	var n models.Notification
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "status").
+		First(&n, "tenant_id = ? AND id = ? AND status IN ?", tenantID, notificationID, receiptPredecessors[outcome]).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Notification{}).Where("id = ?", n.ID).Update("status", outcome).Error; err != nil {
+		return err
+	}
+
+	delta := models.UsageDay{TenantID: tenantID, Channel: channel, Day: time.Now()}
+	switch {
+	case outcome == "delivered":
+		delta.Delivered = 1
+	case outcome == "failed" && n.Status == "delivered":
+		delta.Delivered, delta.Failed = -1, 1
+	case outcome == "failed":
+		delta.Failed = 1
+	default:
+		return nil
+	}
+	return recordUsage(tx, delta)
+}
+
+// ApplyReceipt records receipt as a delivery attempt of the message's
+// notification and moves the notification to the receipt's outcome. It
+// returns the notification's attempt, and reports false when the same
+// report was already applied. gorm.ErrRecordNotFound means no attempt
+// carries the provider message ID.
+func (r *NotificationRepository) ApplyReceipt(receipt Receipt) (*models.DeliveryAttempt, bool, error) {
+	var applied bool
+	var sent *models.DeliveryAttempt
+	err := r.Transaction(func(tx *NotificationRepository) error {
+		var err error
+		if sent, err = tx.FindSent(receipt.Provider, receipt.ProviderMessageID); err != nil {
+			return err
+		}
+		var seen int64
+		if err := tx.db.Model(&models.DeliveryAttempt{}).
+			Where("provider_message_id = ? AND provider = ? AND status = ?", receipt.ProviderMessageID, receipt.Provider, receipt.Status).
+			Count(&seen).Error; err != nil {
+			return err
+		}
+		if seen > 0 {
+			return nil
+		}
+		applied = true
+
+		if err := tx.db.Create(&models.DeliveryAttempt{
+			TenantID:          sent.TenantID,
+			NotificationID:    sent.NotificationID,
+			Channel:           sent.Channel,
+			Provider:          receipt.Provider,
+			Status:            receipt.Status,
+			Error:             receipt.Error,
+			Try:               sent.Try,
+			ProviderMessageID: receipt.ProviderMessageID,
+		}).Error; err != nil {
+			return err
+		}
+		if receipt.Outcome == "" {
+			return nil
+		}
+		return settle(tx.db, sent.TenantID, sent.NotificationID, sent.Channel, receipt.Outcome)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return sent, applied, nil
 }
 
-func (r *NotificationRepository) GetAttemptByID(id uuid.UUID) (*models.DeliveryAttempt, error) {
+// RecordEvent stores a provider event for its notification and, like
+// ApplyReceipt, moves the notification to outcome unless outcome is "". It
+// reports false when the event was already stored.
+func (r *NotificationRepository) RecordEvent(event *models.DeliveryEvent, channel, outcome string) (bool, error) {
+	var stored bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		stored = true
+		if outcome == "" {
+			return nil
+		}
+		return settle(tx, event.TenantID, event.NotificationID, channel, outcome)
+	})
+	return stored, err
+}
+
+func (r *NotificationRepository) ListEvents(tenantID, notificationID uuid.UUID) ([]models.DeliveryEvent, error) {
+	var events []models.DeliveryEvent
+	if err := r.db.
+		Where("tenant_id = ? AND notification_id = ?", tenantID, notificationID).
+		Order("occurred_at").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// RecordAccepted counts n accepted notifications of channel.
+func (r *NotificationRepository) RecordAccepted(tenantID uuid.UUID, channel string, n int64) error {
+	return recordUsage(r.db, models.UsageDay{TenantID: tenantID, Channel: channel, Day: time.Now(), Accepted: n})
+}
+
+func (r *NotificationRepository) GetAttemptByID(tenantID, id uuid.UUID) (*models.DeliveryAttempt, error) {
 	var attempt models.DeliveryAttempt
-	if err := r.db.First(&attempt, "id = ?", id).Error; err != nil {
+	if err := r.db.First(&attempt, "tenant_id = ? AND id = ?", tenantID, id).Error; err != nil {
 		return nil, err
 	}
 	return &attempt, nil
 }
 
-func (r *NotificationRepository) ListAttemptsByNotification(notificationID uuid.UUID) ([]models.DeliveryAttempt, error) {
+func (r *NotificationRepository) ListAttemptsByNotification(tenantID, notificationID uuid.UUID) ([]models.DeliveryAttempt, error) {
 	var attempts []models.DeliveryAttempt
-	if err := r.db.Where("notification_id = ?", notificationID).
+	if err := r.db.Where("tenant_id = ? AND notification_id = ?", tenantID, notificationID).
+		Order("created_at, try").
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// ListAttemptsByNotifications loads the attempts of several notifications in
+// one query, ordered by creation time.
+func (r *NotificationRepository) ListAttemptsByNotifications(tenantID uuid.UUID, notificationIDs []uuid.UUID) ([]models.DeliveryAttempt, error) {
+	var attempts []models.DeliveryAttempt
+	if len(notificationIDs) == 0 {
+		return attempts, nil
+	}
+	if err := r.db.Where("tenant_id = ? AND notification_id IN ?", tenantID, notificationIDs).
+		Order("created_at, try").
 		Find(&attempts).Error; err != nil {
 		return nil, err
 	}
 	return attempts, nil
 }
 
-func (r *NotificationRepository) DeleteAttempt(id uuid.UUID) error {
-	return r.db.Delete(&models.DeliveryAttempt{}, "id = ?", id).Error
+func (r *NotificationRepository) DeleteAttempt(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.DeliveryAttempt{}, "tenant_id = ? AND id = ?", tenantID, id).Error
 }
 
-func (r *NotificationRepository) GetDLQByNotificationID(id uuid.UUID) (*models.DeliveryAttempt, error) {
+func (r *NotificationRepository) GetDLQByNotificationID(tenantID, id uuid.UUID) (*models.DeliveryAttempt, error) {
     var out struct {
         
         Message  []byte
@@ -80,12 +589,13 @@ func (r *NotificationRepository) GetDLQByNotificationID(id uuid.UUID) (*models.D
     if err := r.db.Table("delivery_attempts AS da").
         Joins("JOIN notifications n ON n.id = da.notification_id").
         Select(" da.message, da.channel, da.id, da.notification_id, da.provider, da.status, da.error, da.try, da.latency_ms, da.created_at").
-        Where("da.notification_id = ? AND da.status = ?", id, "dlq").
+        Where("n.tenant_id = ? AND da.notification_id = ? AND da.status = ?", tenantID, id, "dlq").
         Scan(&out).Error; err != nil {
         return nil, err
     }
 
     attempt := &models.DeliveryAttempt{
+        TenantID:       tenantID,
         NotificationID: id,
         Channel:        out.Channel,
         Status:         "dlq",
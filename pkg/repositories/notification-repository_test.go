@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"github.com/jsndz/signalbus/pkg/types"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testDB opens the Postgres database in SIGNALBUS_TEST_DB inside a
+// transaction that is rolled back when the test ends. Tests that need it are
+// skipped when the variable is unset.
+func testDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("SIGNALBUS_TEST_DB")
+	if dsn == "" {
+		t.Skip("SIGNALBUS_TEST_DB is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	if err := tx.AutoMigrate(tables...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return tx
+}
+
+func TestNotificationCursorRoundTrip(t *testing.T) {
+	in := NotificationCursor{
+		CreatedAt: time.Date(2025, 3, 1, 10, 30, 0, 123000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	out, err := DecodeNotificationCursor(in.Encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !out.CreatedAt.Equal(in.CreatedAt) || out.ID != in.ID {
+		t.Errorf("expected %+v, got %+v", in, *out)
+	}
+}
+
+func TestDecodeNotificationCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "not-base64!", "e30"} {
+		if _, err := DecodeNotificationCursor(s); err == nil {
+			t.Errorf("expected error for cursor %q", s)
+		}
+	}
+}
+
+func TestDLQAttemptRedrivesEmail(t *testing.T) {
+	msg := types.KafkaStreamData{
+		TenantID:       uuid.New(),
+		NotificationId: uuid.New(),
+		IdempotencyKey: "order-42",
+		RecieverData:   map[string]interface{}{"to": []interface{}{"ada@example.com"}, "from": "shop@example.com", "subject": "Your order"},
+		InTemplateData: map[string]interface{}{"order_id": "42"},
+		GetTemplateData: &types.GetTemplateData{
+			EventType: "order.shipped",
+			Locale:    "en-US",
+		},
+	}
+
+	attempt, err := NewDLQAttempt(msg, "email", "sendgrid")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempt.Status != "dlq" || attempt.TenantID != msg.TenantID || attempt.NotificationID != msg.NotificationId {
+		t.Fatalf("unexpected attempt %+v", attempt)
+	}
+
+	redriven, err := types.DecodeDLQMessage(attempt.Message)
+	if err != nil {
+		t.Fatalf("redrive: %v", err)
+	}
+	if redriven.RecieverData["from"] != "shop@example.com" || redriven.InTemplateData["order_id"] != "42" {
+		t.Errorf("recipient or template data lost: %+v", redriven)
+	}
+	if redriven.GetTemplateData == nil || redriven.GetTemplateData.EventType != "order.shipped" {
+		t.Errorf("template lookup lost: %+v", redriven.GetTemplateData)
+	}
+}
+
+func TestApplyReceiptStoresSentAfterWorkerAttempt(t *testing.T) {
+	db := testDB(t, &models.Notification{}, &models.DeliveryAttempt{}, &models.UsageDay{})
+	repo := NewNotificationRepository(db)
+
+	n := models.Notification{TenantID: uuid.New(), Topic: "order.shipped", Channel: "sms", Status: "sent"}
+	if err := db.Create(&n).Error; err != nil {
+		t.Fatalf("create notification: %v", err)
+	}
+	// what the SMS worker records when Twilio accepts the message
+	if err := repo.CreateAttempt(&models.DeliveryAttempt{
+		TenantID:          n.TenantID,
+		NotificationID:    n.ID,
+		Channel:           "sms",
+		Provider:          "twilio",
+		Status:            types.AttemptAccepted,
+		Try:               1,
+		ProviderMessageID: "SM123",
+	}); err != nil {
+		t.Fatalf("create attempt: %v", err)
+	}
+
+	for _, status := range []string{"queued", "sent"} {
+		_, applied, err := repo.ApplyReceipt(Receipt{Provider: "twilio", ProviderMessageID: "SM123", Status: status})
+		if err != nil || !applied {
+			t.Fatalf("%s receipt: applied %v, err %v", status, applied, err)
+		}
+	}
+	if _, applied, err := repo.ApplyReceipt(Receipt{Provider: "twilio", ProviderMessageID: "SM123", Status: "sent"}); err != nil || applied {
+		t.Fatalf("repeated sent receipt: applied %v, err %v", applied, err)
+	}
+
+	var statuses []string
+	if err := db.Model(&models.DeliveryAttempt{}).Where("notification_id = ?", n.ID).
+		Order("created_at").Pluck("status", &statuses).Error; err != nil {
+		t.Fatalf("list attempts: %v", err)
+	}
+	if len(statuses) != 3 || statuses[0] != types.AttemptAccepted || statuses[2] != "sent" {
+		t.Errorf("unexpected attempts %v", statuses)
+	}
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Transaction runs fn with a repository bound to a single transaction. Rows
+// locked by LockDue stay locked until fn returns.
+func (r *OutboxRepository) Transaction(fn func(tx *OutboxRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&OutboxRepository{db: tx})
+	})
+}
+
+func (r *OutboxRepository) Create(msg *models.OutboxMessage) error {
+	return r.db.Create(msg).Error
+}
+
+// LockDue selects pending messages whose AvailableAt has passed, skipping
+// rows already locked by another relay so replicas can share the table.
+func (r *OutboxRepository) LockDue(now time.Time, limit int) ([]models.OutboxMessage, error) {
+	var msgs []models.OutboxMessage
+	if err := r.db.
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND available_at <= ?", "pending", now).
+		Order("available_at, created_at").
+		Limit(limit).
+		Find(&msgs).Error; err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *OutboxRepository) MarkSent(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"status": "sent", "sent_at": at}).Error
+}
+
+func (r *OutboxRepository) MarkRetry(id uuid.UUID, attempts int, lastErr string, availableAt time.Time) error {
+	return r.db.Model(&models.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":     attempts,
+			"last_error":   lastErr,
+			"available_at": availableAt,
+		}).Error
+}
+
+// MarkFailed gives up on the message and fails the notification it carried.
+func (r *OutboxRepository) MarkFailed(msg *models.OutboxMessage, attempts int, lastErr string) error {
+	if err := r.db.Model(&models.OutboxMessage{}).
+		Where("id = ?", msg.ID).
+		Updates(map[string]interface{}{
+			"status":     "failed",
+			"attempts":   attempts,
+			"last_error": lastErr,
+		}).Error; err != nil {
+		return err
+	}
+	if msg.NotificationID == nil {
+		return nil
+	}
+	return r.db.Model(&models.Notification{}).
+		Where("tenant_id = ? AND id = ?", msg.TenantID, *msg.NotificationID).
+		Update("status", "failed").Error
+}
+
+func (r *OutboxRepository) CountPending() (int64, error) {
+	var n int64
+	err := r.db.Model(&models.OutboxMessage{}).Where("status = ?", "pending").Count(&n).Error
+	return n, err
+}
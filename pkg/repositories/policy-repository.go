@@ -1,9 +1,13 @@
 package repositories
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/pkg/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 type PolicyRepository struct {
 	db *gorm.DB
@@ -13,18 +17,144 @@ func NewPolicyRepository(db *gorm.DB) *PolicyRepository {
 	return &PolicyRepository{db: db}
 }
 
-func (r *PolicyRepository) Create(policy *models.Policy) error {
-	return r.db.Create(policy).Error
+// policyColumns are what an update may change; the topic is fixed.
+var policyColumns = []string{
+	"channels", "locale", "transactional", "urgent",
+	"quiet_start", "quiet_end", "quiet_timezone",
+	"fallback", "collapse_window", "collapse_mode",
+	"digest_window", "digest_template",
+	"version", "updated_at",
 }
 
-func (r *PolicyRepository) GetByTopic(topic string) (*models.Policy, error) {
+// Create stores the policy together with its rules, numbering the rules in
+// the order given, and records it as version 1.
+func (r *PolicyRepository) Create(policy *models.Policy, changedBy *uuid.UUID) error {
+	for i := range policy.Rules {
+		policy.Rules[i].Position = i
+	}
+	policy.Version = 1
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(policy).Error; err != nil {
+			return err
+		}
+		return createVersion(tx, policy, "created", changedBy)
+	})
+}
+
+// Update replaces the policy's settings and rules and records a new version.
+// It returns gorm.ErrRecordNotFound when the policy does not exist.
+func (r *PolicyRepository) Update(policy *models.Policy, change string, changedBy *uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Policy
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&current, "tenant_id = ? AND id = ?", policy.TenantID, policy.ID).Error; err != nil {
+			return err
+		}
+		policy.Topic = current.Topic
+		policy.CreatedAt = current.CreatedAt
+		policy.Version = current.Version + 1
+
+		if err := tx.Model(&models.Policy{ID: policy.ID}).
+			Select(policyColumns).
+			Omit(clause.Associations).
+			Updates(policy).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("policy_id = ?", policy.ID).Delete(&models.PolicyRule{}).Error; err != nil {
+			return err
+		}
+		for i := range policy.Rules {
+			policy.Rules[i].ID = uuid.Nil
+			policy.Rules[i].PolicyID = policy.ID
+			policy.Rules[i].Position = i
+		}
+		if len(policy.Rules) > 0 {
+			if err := tx.Create(&policy.Rules).Error; err != nil {
+				return err
+			}
+		}
+		return createVersion(tx, policy, change, changedBy)
+	})
+}
+
+func createVersion(tx *gorm.DB, policy *models.Policy, change string, changedBy *uuid.UUID) error {
+	snapshot, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("snapshot policy: %w", err)
+	}
+	return tx.Create(&models.PolicyVersion{
+		TenantID:  policy.TenantID,
+		PolicyID:  policy.ID,
+		Version:   policy.Version,
+		Channels:  policy.Channels,
+		Snapshot:  string(snapshot),
+		Change:    change,
+		ChangedBy: changedBy,
+	}).Error
+}
+
+func orderedRules(db *gorm.DB) *gorm.DB {
+	return db.Order("priority DESC, position")
+}
+
+func (r *PolicyRepository) GetByTopic(tenantID uuid.UUID, topic string) (*models.Policy, error) {
 	var policy models.Policy
-	if err := r.db.First(&policy, "topic = ?", topic).Error; err != nil {
+	if err := r.db.
+		Preload("Rules", orderedRules).
+		First(&policy, "tenant_id = ? AND topic = ?", tenantID, topic).Error; err != nil {
 		return nil, err
 	}
 	return &policy, nil
 }
 
-func (r *PolicyRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.Policy{}, "id = ?", id).Error
+func (r *PolicyRepository) GetByID(tenantID, id uuid.UUID) (*models.Policy, error) {
+	var policy models.Policy
+	if err := r.db.
+		Preload("Rules", orderedRules).
+		First(&policy, "tenant_id = ? AND id = ?", tenantID, id).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *PolicyRepository) List(tenantID uuid.UUID) ([]models.Policy, error) {
+	var policies []models.Policy
+	if err := r.db.
+		Preload("Rules", orderedRules).
+		Where("tenant_id = ?", tenantID).
+		Order("topic").
+		Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// ListVersions returns the policy's history, newest first.
+func (r *PolicyRepository) ListVersions(tenantID, policyID uuid.UUID) ([]models.PolicyVersion, error) {
+	var versions []models.PolicyVersion
+	if err := r.db.
+		Where("tenant_id = ? AND policy_id = ?", tenantID, policyID).
+		Order("version DESC").
+		Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetVersion returns the policy as it stood at version.
+func (r *PolicyRepository) GetVersion(tenantID, policyID uuid.UUID, version int) (*models.Policy, error) {
+	var v models.PolicyVersion
+	if err := r.db.
+		First(&v, "tenant_id = ? AND policy_id = ? AND version = ?", tenantID, policyID, version).Error; err != nil {
+		return nil, err
+	}
+	var policy models.Policy
+	if err := json.Unmarshal([]byte(v.Snapshot), &policy); err != nil {
+		return nil, fmt.Errorf("decode policy version %d: %w", version, err)
+	}
+	return &policy, nil
+}
+
+func (r *PolicyRepository) Delete(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.Policy{}, "tenant_id = ? AND id = ?", tenantID, id).Error
 }
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewPreferenceRepository(db *gorm.DB) *PreferenceRepository {
+	return &PreferenceRepository{db: db}
+}
+
+// Upsert creates the preference or updates OptIn on the existing row for the
+// same user, topic and channel.
+func (r *PreferenceRepository) Upsert(pref *models.UserPreference) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "user_ref"}, {Name: "topic"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"opt_in", "updated_at"}),
+	}).Create(pref).Error
+}
+
+func (r *PreferenceRepository) GetByID(tenantID, id uuid.UUID) (*models.UserPreference, error) {
+	var pref models.UserPreference
+	if err := r.db.First(&pref, "tenant_id = ? AND id = ?", tenantID, id).Error; err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+func (r *PreferenceRepository) ListByUser(tenantID uuid.UUID, userRef string) (models.UserPreferences, error) {
+	var prefs models.UserPreferences
+	if err := r.db.
+		Where("tenant_id = ? AND user_ref = ?", tenantID, userRef).
+		Order("topic, channel").
+		Find(&prefs).Error; err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func (r *PreferenceRepository) Update(pref *models.UserPreference) error {
+	res := r.db.Model(pref).
+		Where("tenant_id = ?", pref.TenantID).
+		Select("opt_in").
+		Updates(pref)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *PreferenceRepository) Delete(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.UserPreference{}, "tenant_id = ? AND id = ?", tenantID, id).Error
+}
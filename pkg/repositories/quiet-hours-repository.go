@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type QuietHoursRepository struct {
+	db *gorm.DB
+}
+
+func NewQuietHoursRepository(db *gorm.DB) *QuietHoursRepository {
+	return &QuietHoursRepository{db: db}
+}
+
+// Upsert stores the user's window, replacing an existing one.
+func (r *QuietHoursRepository) Upsert(q *models.QuietHours) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "user_ref"}},
+		DoUpdates: clause.AssignmentColumns([]string{"start", "end", "timezone", "updated_at"}),
+	}).Create(q).Error
+}
+
+func (r *QuietHoursRepository) GetByUser(tenantID uuid.UUID, userRef string) (*models.QuietHours, error) {
+	var q models.QuietHours
+	if err := r.db.First(&q, "tenant_id = ? AND user_ref = ?", tenantID, userRef).Error; err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// EffectiveWindow returns the user's own window, falling back to the policy
+// default when the user has none. policy may be nil.
+func (r *QuietHoursRepository) EffectiveWindow(tenantID uuid.UUID, userRef string, policy *models.Policy) (models.QuietWindow, error) {
+	q, err := r.GetByUser(tenantID, userRef)
+	if err == nil {
+		return q.QuietWindow, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.QuietWindow{}, err
+	}
+	if policy == nil {
+		return models.QuietWindow{}, nil
+	}
+	return policy.QuietHours, nil
+}
+
+func (r *QuietHoursRepository) Delete(tenantID uuid.UUID, userRef string) error {
+	return r.db.Delete(&models.QuietHours{}, "tenant_id = ? AND user_ref = ?", tenantID, userRef).Error
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SuppressionRepository struct {
+	db *gorm.DB
+}
+
+func NewSuppressionRepository(db *gorm.DB) *SuppressionRepository {
+	return &SuppressionRepository{db: db}
+}
+
+// suppressionUpsert replaces the reason, source and expiry of an existing
+// suppression for the same address, so adding one twice renews it.
+var suppressionUpsert = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "channel"}, {Name: "address"}},
+	DoUpdates: clause.AssignmentColumns([]string{"reason", "source", "expires_at", "updated_at"}),
+}
+
+func (r *SuppressionRepository) Upsert(s *models.Suppression) error {
+	return r.db.Clauses(suppressionUpsert).Create(s).Error
+}
+
+// Import upserts every suppression in one transaction. The addresses must
+// be distinct per channel.
+func (r *SuppressionRepository) Import(list []models.Suppression) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(suppressionUpsert).CreateInBatches(&list, 500).Error
+	})
+}
+
+func (r *SuppressionRepository) GetByID(tenantID, id uuid.UUID) (*models.Suppression, error) {
+	var s models.Suppression
+	if err := r.db.First(&s, "tenant_id = ? AND id = ?", tenantID, id).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// List returns the tenant's suppressions, newest first, optionally only
+// those of channel and reason. Expired ones are included.
+func (r *SuppressionRepository) List(tenantID uuid.UUID, channel, reason string) ([]models.Suppression, error) {
+	q := r.db.Where("tenant_id = ?", tenantID)
+	if channel != "" {
+		q = q.Where("channel = ?", channel)
+	}
+	if reason != "" {
+		q = q.Where("reason = ?", reason)
+	}
+	var list []models.Suppression
+	if err := q.Order("created_at DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Matching returns the suppressions in force at now for any of addresses on
+// channel, keyed by address. Platform-wide suppressions count for every
+// tenant. Addresses must be in the form models.SuppressionAddress gives.
+func (r *SuppressionRepository) Matching(tenantID uuid.UUID, channel string, addresses []string, now time.Time) (map[string]models.Suppression, error) {
+	matches := make(map[string]models.Suppression)
+	if len(addresses) == 0 {
+		return matches, nil
+	}
+	var list []models.Suppression
+	if err := r.db.
+		Where("tenant_id IN ? AND channel = ? AND address IN ?", []uuid.UUID{tenantID, uuid.Nil}, channel, addresses).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range list {
+		matches[s.Address] = s
+	}
+	return matches, nil
+}
+
+// Filter drops the suppressed ones from addresses. It returns the rest, in
+// their original form, and the suppression that dropped the first one, or
+// nil when none was dropped.
+func (r *SuppressionRepository) Filter(tenantID uuid.UUID, channel string, addresses []string, now time.Time) ([]string, *models.Suppression, error) {
+	keys := make([]string, len(addresses))
+	for i, addr := range addresses {
+		keys[i] = models.SuppressionAddress(channel, addr)
+	}
+	matches, err := r.Matching(tenantID, channel, keys, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	var hit *models.Suppression
+	kept := make([]string, 0, len(addresses))
+	for i, addr := range addresses {
+		s, ok := matches[keys[i]]
+		if !ok {
+			kept = append(kept, addr)
+			continue
+		}
+		if hit == nil {
+			hit = &s
+		}
+	}
+	return kept, hit, nil
+}
+
+func (r *SuppressionRepository) Delete(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.Suppression{}, "tenant_id = ? AND id = ?", tenantID, id).Error
+}
+
+// Lift deletes the suppression of address on channel if it was added for
+// reason. Suppressions added for other reasons stay.
+func (r *SuppressionRepository) Lift(tenantID uuid.UUID, channel, address, reason string) error {
+	return r.db.Delete(&models.Suppression{},
+		"tenant_id = ? AND channel = ? AND address = ? AND reason = ?", tenantID, channel, address, reason).Error
+}
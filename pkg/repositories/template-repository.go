@@ -20,17 +20,17 @@ func (r *TemplateRepository) Create(template *models.Template) error {
 	return r.db.Create(template).Error
 }
 
-func (r *TemplateRepository) GetByID(id uuid.UUID) (*models.Template, error) {
+func (r *TemplateRepository) GetByID(tenantID, id uuid.UUID) (*models.Template, error) {
 	var template models.Template
-	if err := r.db.First(&template, "id = ?", id).Error; err != nil {
+	if err := r.db.First(&template, "tenant_id = ? AND id = ?", tenantID, id).Error; err != nil {
 		return nil, err
 	}
 	return &template, nil
 }
 
-func (r *TemplateRepository) GetByName( name, locale string) (*models.Template, error) {
+func (r *TemplateRepository) GetByName(tenantID uuid.UUID, name, locale string) (*models.Template, error) {
 	var template models.Template
-	if err := r.db.Where("name = ? AND locale = ?", name, locale).
+	if err := r.db.Where("tenant_id = ? AND name = ? AND locale = ?", tenantID, name, locale).
 		First(&template).Error; err != nil {
 		return nil, err
 	}
@@ -42,18 +42,28 @@ func (r *TemplateRepository) Update(template *models.Template) error {
 	if template.ID == uuid.Nil {
 		return errors.New("invalid template ID")
 	}
-	return r.db.Save(template).Error
+	res := r.db.Model(template).
+		Where("tenant_id = ?", template.TenantID).
+		Select("name", "channel", "content_type", "locale", "content").
+		Updates(template)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
-func (r *TemplateRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.Template{}, "id = ?", id).Error
+func (r *TemplateRepository) Delete(tenantID, id uuid.UUID) error {
+	return r.db.Delete(&models.Template{}, "tenant_id = ? AND id = ?", tenantID, id).Error
 }
 
-func (r *TemplateRepository) GetByLookup( channel, name, locale, contentType string) (*models.Template, error) {
+func (r *TemplateRepository) GetByLookup(tenantID uuid.UUID, channel, name, locale, contentType string) (*models.Template, error) {
 	var template models.Template
 	err := r.db.Where(
-		" channel = ? AND name = ? AND locale = ? AND content_type = ?",
-		 channel, name, locale, contentType,
+		"tenant_id = ? AND channel = ? AND name = ? AND locale = ? AND content_type = ?",
+		tenantID, channel, name, locale, contentType,
 	).First(&template).Error
 
 	if err != nil {
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+)
+
+type TenantRepository struct {
+	db *gorm.DB
+}
+
+func NewTenantRepository(db *gorm.DB) *TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+func (r *TenantRepository) Create(tenant *models.Tenant) error {
+	return r.db.Create(tenant).Error
+}
+
+func (r *TenantRepository) GetByID(id uuid.UUID) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := r.db.First(&tenant, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (r *TenantRepository) List() ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	if err := r.db.Order("created_at").Find(&tenants).Error; err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+func (r *TenantRepository) Update(tenant *models.Tenant) error {
+	res := r.db.Model(tenant).
+		Select("name", "plan", "quota_daily", "quota_monthly").
+		Updates(tenant)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *TenantRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&models.Tenant{}, "id = ?", id).Error
+}
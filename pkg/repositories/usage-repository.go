@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/metrics"
+	"github.com/jsndz/signalbus/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UsageRepository struct {
+	db *gorm.DB
+}
+
+func NewUsageRepository(db *gorm.DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// recordUsage adds delta to the ledger row of its tenant, channel and day.
+// It takes the caller's db so the count commits with what is counted.
+func recordUsage(db *gorm.DB, delta models.UsageDay) error {
+	delta.Day = models.UsageDate(delta.Day)
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_id"}, {Name: "channel"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"accepted":  gorm.Expr("usage_days.accepted + EXCLUDED.accepted"),
+			"delivered": gorm.Expr("usage_days.delivered + EXCLUDED.delivered"),
+			"failed":    gorm.Expr("usage_days.failed + EXCLUDED.failed"),
+		}),
+	}).Create(&delta).Error
+	if err != nil {
+		return err
+	}
+	if delta.Accepted > 0 {
+		metrics.TenantUsageTotal.WithLabelValues(delta.TenantID.String(), delta.Channel).Add(float64(delta.Accepted))
+	}
+	return nil
+}
+
+// Range returns the ledger rows from from to to, both days inclusive,
+// optionally for one channel.
+func (r *UsageRepository) Range(tenantID uuid.UUID, from, to time.Time, channel string) ([]models.UsageDay, error) {
+	q := r.db.Where("tenant_id = ? AND day BETWEEN ? AND ?", tenantID, models.UsageDate(from), models.UsageDate(to))
+	if channel != "" {
+		q = q.Where("channel = ?", channel)
+	}
+	var days []models.UsageDay
+	if err := q.Order("day, channel").Find(&days).Error; err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// AcceptedSince sums what the tenant has had accepted since from, per
+// channel.
+func (r *UsageRepository) AcceptedSince(tenantID uuid.UUID, from time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Channel  string
+		Accepted int64
+	}
+	if err := r.db.Model(&models.UsageDay{}).
+		Select("channel, SUM(accepted) AS accepted").
+		Where("tenant_id = ? AND day >= ?", tenantID, models.UsageDate(from)).
+		Group("channel").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	sums := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		sums[row.Channel] = row.Accepted
+	}
+	return sums, nil
+}
+
+// QuotaError is returned when accepting more notifications would exceed one
+// of the tenant's quotas. Status is 402 for a monthly quota, which lasts
+// until the month turns or the quota is raised, and 429 for the daily one.
+type QuotaError struct {
+	Status int
+	// Period is "daily" or "monthly".
+	Period string
+	// Channel is set when the channel's own quota was hit.
+	Channel string
+	Quota   int64
+	Used    int64
+	// Resets is when the period ends.
+	Resets time.Time
+}
+
+func (e *QuotaError) Error() string {
+	scope := "tenant"
+	if e.Channel != "" {
+		scope = e.Channel
+	}
+	return fmt.Sprintf("%s %s quota of %d notifications exhausted (%d used)", scope, e.Period, e.Quota, e.Used)
+}
+
+// CheckQuota returns a *QuotaError when accepting want more notifications
+// per channel would go over the tenant's monthly, per-channel monthly or
+// daily quota. A tenant quota of 0 is unlimited.
+func (r *UsageRepository) CheckQuota(tenantID uuid.UUID, want map[string]int64, now time.Time) error {
+	var total int64
+	for _, n := range want {
+		total += n
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var tenant models.Tenant
+	if err := r.db.First(&tenant, "id = ?", tenantID).Error; err != nil {
+		return err
+	}
+	month, err := r.AcceptedSince(tenantID, models.MonthStart(now))
+	if err != nil {
+		return err
+	}
+	nextMonth := models.MonthStart(now).AddDate(0, 1, 0)
+
+	if quota := int64(tenant.QuotaMonthly); quota > 0 {
+		if used := sumAccepted(month); used+total > quota {
+			return &QuotaError{Status: http.StatusPaymentRequired, Period: "monthly", Quota: quota, Used: used, Resets: nextMonth}
+		}
+	}
+	quotas, err := r.ListQuotas(tenantID)
+	if err != nil {
+		return err
+	}
+	for _, q := range quotas {
+		if n := want[q.Channel]; n > 0 && month[q.Channel]+n > q.Monthly {
+			return &QuotaError{Status: http.StatusPaymentRequired, Period: "monthly", Channel: q.Channel,
+				Quota: q.Monthly, Used: month[q.Channel], Resets: nextMonth}
+		}
+	}
+
+	if quota := int64(tenant.QuotaDaily); quota > 0 {
+		today, err := r.AcceptedSince(tenantID, models.UsageDate(now))
+		if err != nil {
+			return err
+		}
+		if used := sumAccepted(today); used+total > quota {
+			return &QuotaError{Status: http.StatusTooManyRequests, Period: "daily", Quota: quota, Used: used,
+				Resets: models.UsageDate(now).AddDate(0, 0, 1)}
+		}
+	}
+	return nil
+}
+
+func sumAccepted(counts map[string]int64) int64 {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
+
+func (r *UsageRepository) ListQuotas(tenantID uuid.UUID) ([]models.ChannelQuota, error) {
+	var quotas []models.ChannelQuota
+	if err := r.db.Where("tenant_id = ?", tenantID).Order("channel").Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+func (r *UsageRepository) SetQuota(quota *models.ChannelQuota) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"monthly", "updated_at"}),
+	}).Create(quota).Error
+}
+
+func (r *UsageRepository) DeleteQuota(tenantID uuid.UUID, channel string) error {
+	return r.db.Delete(&models.ChannelQuota{}, "tenant_id = ? AND channel = ?", tenantID, channel).Error
+}
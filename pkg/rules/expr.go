@@ -0,0 +1,398 @@
+// Package rules evaluates the conditions attached to policy rules.
+//
+// A condition is a small boolean expression over the notify request:
+//
+//	data.amount > 1000 && recipient.country == "IN"
+//
+// It supports the comparisons == != > >= < <=, the operators && || !,
+// parentheses, and string, number, true, false and null literals. Paths
+// start at one of the roots in Roots. A path that does not exist is null,
+// and comparing values of different types is false rather than an error,
+// so a rule over an optional field simply does not match.
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Roots are the names a path may start with.
+var Roots = []string{"data", "template_data", "recipient", "event_type", "user_ref"}
+
+// Env is what a condition is evaluated against, keyed by root.
+type Env map[string]interface{}
+
+// Expr is a compiled condition.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses src.
+func Compile(src string) (*Expr, error) {
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Expr{src: src, root: n}, nil
+}
+
+func (e *Expr) String() string { return e.src }
+
+// Eval reports whether the condition holds for env.
+func (e *Expr) Eval(env Env) bool {
+	v, _ := e.root.eval(env).(bool)
+	return v
+}
+
+type node interface {
+	eval(env Env) interface{}
+}
+
+type literal struct{ v interface{} }
+
+func (n literal) eval(Env) interface{} { return n.v }
+
+type path []string
+
+func (n path) eval(env Env) interface{} {
+	var cur interface{} = map[string]interface{}(env)
+	for _, part := range n {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return normalize(cur)
+}
+
+type not struct{ x node }
+
+func (n not) eval(env Env) interface{} {
+	v, _ := n.x.eval(env).(bool)
+	return !v
+}
+
+type logical struct {
+	op   string
+	l, r node
+}
+
+func (n logical) eval(env Env) interface{} {
+	l, _ := n.l.eval(env).(bool)
+	if n.op == "&&" && !l {
+		return false
+	}
+	if n.op == "||" && l {
+		return true
+	}
+	r, _ := n.r.eval(env).(bool)
+	return r
+}
+
+type compare struct {
+	op   string
+	l, r node
+}
+
+func (n compare) eval(env Env) interface{} {
+	l, r := n.l.eval(env), n.r.eval(env)
+	switch n.op {
+	case "==":
+		return equal(l, r)
+	case "!=":
+		return !equal(l, r)
+	}
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		return ok && ordered(n.op, compareFloat(lv, rv))
+	case string:
+		rv, ok := r.(string)
+		return ok && ordered(n.op, strings.Compare(lv, rv))
+	}
+	return false
+}
+
+// equal is == that does not panic on objects and arrays; they never equal
+// anything.
+func equal(l, r interface{}) bool {
+	for _, v := range []interface{}{l, r} {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return l == r
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func ordered(op string, c int) bool {
+	switch op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// normalize brings numbers to float64 so values decoded from JSON and values
+// set in Go compare equal.
+func normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case json.Number:
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	}
+	return v
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	i      int
+}
+
+func (p *parser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return fmt.Errorf("unterminated string at %d", i)
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return fmt.Errorf("invalid string at %d", i)
+			}
+			p.tokens = append(p.tokens, token{tokString, text, i})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(s) && unicode.IsDigit(rune(s[i+1]))):
+			j := i + 1
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokNumber, s[i:j], i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_' || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokIdent, s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", ">=", "<=", "&&", "||", ">", "<", "!", "(", ")"} {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			p.tokens = append(p.tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+func (p *parser) peek() token {
+	if p.i >= len(p.tokens) {
+		return token{kind: tokEOF, pos: len(p.src)}
+	}
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.i++
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "||" {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = logical{"||", l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "&&" {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = logical{"&&", l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "!" {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return not{x}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp {
+		return l, nil
+	}
+	switch t.text {
+	case "==", "!=", ">", ">=", "<", "<=":
+		p.next()
+		r, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return compare{t.text, l, r}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+		}
+		return literal{f}, nil
+	case tokString:
+		return literal{t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		parts := strings.Split(t.text, ".")
+		for _, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("invalid path %q at %d", t.text, t.pos)
+			}
+		}
+		if !isRoot(parts[0]) {
+			return nil, fmt.Errorf("unknown name %q at %d, paths start with one of %s", parts[0], t.pos, strings.Join(Roots, ", "))
+		}
+		return path(parts), nil
+	case tokOp:
+		if t.text == "(" {
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if c := p.next(); c.kind != tokOp || c.text != ")" {
+				return nil, fmt.Errorf("missing ) at %d", c.pos)
+			}
+			return n, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func isRoot(name string) bool {
+	for _, r := range Roots {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jsndz/signalbus/pkg/models"
+)
+
+// ChannelProviders lists the providers a rule may override to, per channel.
+var ChannelProviders = map[string][]string{
+	"email": {"smtp", "sendgrid"},
+	"sms":   {"twilio"},
+}
+
+// Outcome is the route chosen for one recipient.
+type Outcome struct {
+	// Rule is the rule that matched, nil when the default applied.
+	Rule     *models.PolicyRule
+	Channels []string
+	Provider string
+}
+
+// ProviderFor returns the provider override for channel, or "" to use the
+// worker's default.
+func (o Outcome) ProviderFor(channel string) string {
+	if o.Provider == "" {
+		return ""
+	}
+	for _, p := range ChannelProviders[channel] {
+		if p == o.Provider {
+			return o.Provider
+		}
+	}
+	return ""
+}
+
+// String describes the outcome for Notification.RuleOutcome.
+func (o Outcome) String() string {
+	if o.Rule == nil {
+		return "default rule"
+	}
+	name := o.Rule.Name
+	if name == "" {
+		name = fmt.Sprintf("#%d", o.Rule.Position+1)
+	}
+	return fmt.Sprintf("rule %s matched: %s", name, o.Rule.Condition)
+}
+
+// Select returns the route for env: the first matching rule, or the policy's
+// own channels when none match.
+func Select(policy *models.Policy, env Env) (Outcome, error) {
+	for _, rule := range Ordered(policy.Rules) {
+		expr, err := Compile(rule.Condition)
+		if err != nil {
+			return Outcome{}, fmt.Errorf("policy rule %q: %w", rule.Name, err)
+		}
+		if expr.Eval(env) {
+			r := rule
+			return Outcome{Rule: &r, Channels: rule.Channels, Provider: rule.Provider}, nil
+		}
+	}
+	return Outcome{Channels: policy.Channels}, nil
+}
+
+// Ordered returns rules in evaluation order: by descending Priority, then by
+// Position.
+func Ordered(rules []models.PolicyRule) []models.PolicyRule {
+	out := append([]models.PolicyRule(nil), rules...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Priority != out[j].Priority {
+			return out[i].Priority > out[j].Priority
+		}
+		return out[i].Position < out[j].Position
+	})
+	return out
+}
+
+// Validate checks a policy's rules before it is stored.
+func Validate(policy *models.Policy) error {
+	if err := validateChannels(policy.Channels); err != nil {
+		return err
+	}
+	if err := validateChannels(policy.Fallback); err != nil {
+		return fmt.Errorf("fallback: %w", err)
+	}
+	seen := make(map[string]bool)
+	for _, ch := range policy.Fallback {
+		if seen[ch] {
+			return fmt.Errorf("fallback: %q appears twice", ch)
+		}
+		seen[ch] = true
+	}
+	for i, rule := range policy.Rules {
+		label := rule.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Condition == "" {
+			return fmt.Errorf("rule %s: condition is required", label)
+		}
+		if _, err := Compile(rule.Condition); err != nil {
+			return fmt.Errorf("rule %s: %w", label, err)
+		}
+		if len(rule.Channels) == 0 {
+			return fmt.Errorf("rule %s: at least one channel is required", label)
+		}
+		if err := validateChannels(rule.Channels); err != nil {
+			return fmt.Errorf("rule %s: %w", label, err)
+		}
+		if rule.Provider != "" && (Outcome{Channels: rule.Channels, Provider: rule.Provider}).providerUnused() {
+			return fmt.Errorf("rule %s: provider %q does not serve any of its channels", label, rule.Provider)
+		}
+	}
+	return nil
+}
+
+func (o Outcome) providerUnused() bool {
+	for _, ch := range o.Channels {
+		if o.ProviderFor(ch) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func validateChannels(channels []string) error {
+	for _, ch := range channels {
+		if _, ok := ChannelProviders[ch]; !ok {
+			return fmt.Errorf("unknown channel %q", ch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/jsndz/signalbus/pkg/models"
+)
+
+func TestEval(t *testing.T) {
+	env := Env{
+		"data":       map[string]interface{}{"amount": float64(1500), "tier": "gold", "items": []interface{}{1}},
+		"recipient":  map[string]interface{}{"country": "IN"},
+		"event_type": "order_paid",
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`data.amount > 1000`, true},
+		{`data.amount <= 1000`, false},
+		{`recipient.country == "IN"`, true},
+		{`recipient.country != "IN"`, false},
+		{`data.amount > 1000 && recipient.country == "US"`, false},
+		{`data.amount > 1000 || recipient.country == "US"`, true},
+		{`!(data.tier == "gold")`, false},
+		{`data.missing == null`, true},
+		{`data.missing > 1`, false},
+		{`data.tier > 1`, false},
+		{`data.items == data.items`, false},
+		{`event_type == "order_paid"`, true},
+		{`data.amount >= -5.5`, true},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if got := expr.Eval(env); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`data.amount >`,
+		`amount > 1`,
+		`data.amount > 1 &&`,
+		`(data.amount > 1`,
+		`data.name == "unterminated`,
+		`data.amount $ 1`,
+		`data..amount == 1`,
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("Compile(%q) succeeded, want error", src)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	policy := &models.Policy{
+		Channels: []string{"email"},
+		Rules: []models.PolicyRule{
+			{Name: "big", Condition: `data.amount > 1000`, Channels: []string{"sms"}, Position: 0},
+			{Name: "india", Condition: `recipient.country == "IN"`, Channels: []string{"sms", "email"}, Provider: "sendgrid", Priority: 1, Position: 1},
+		},
+	}
+
+	out, err := Select(policy, Env{"data": map[string]interface{}{"amount": 5000}, "recipient": map[string]interface{}{"country": "IN"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Rule == nil || out.Rule.Name != "india" {
+		t.Fatalf("got %v, want the higher priority rule", out)
+	}
+	if out.ProviderFor("email") != "sendgrid" || out.ProviderFor("sms") != "" {
+		t.Errorf("provider override applied to the wrong channel")
+	}
+
+	out, err = Select(policy, Env{"data": map[string]interface{}{"amount": 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Rule != nil || len(out.Channels) != 1 || out.Channels[0] != "email" {
+		t.Errorf("got %v, want the default rule", out)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []models.PolicyRule{
+		{Condition: ``, Channels: []string{"sms"}},
+		{Condition: `data.x ==`, Channels: []string{"sms"}},
+		{Condition: `data.x == 1`},
+		{Condition: `data.x == 1`, Channels: []string{"fax"}},
+		{Condition: `data.x == 1`, Channels: []string{"sms"}, Provider: "sendgrid"},
+	}
+	for i, rule := range bad {
+		p := &models.Policy{Channels: []string{"email"}, Rules: []models.PolicyRule{rule}}
+		if err := Validate(p); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+
+	ok := &models.Policy{Channels: []string{"email"}, Rules: []models.PolicyRule{
+		{Condition: `data.x == 1`, Channels: []string{"sms", "email"}, Provider: "twilio"},
+	}}
+	if err := Validate(ok); err != nil {
+		t.Error(err)
+	}
+}
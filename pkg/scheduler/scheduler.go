@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsndz/signalbus/pkg/repositories"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Config struct {
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:    500,
+		PollInterval: time.Second,
+	}
+}
+
+// Scheduler releases scheduled and deferred notifications once their SendAt
+// passes by moving them and their outbox messages to pending; outbox.Relay
+// then publishes them to notification.<channel>. Each batch is claimed with
+// FOR UPDATE SKIP LOCKED, so every API replica can run one.
+type Scheduler struct {
+	repo   *repositories.NotificationRepository
+	logger *zap.Logger
+	cfg    Config
+}
+
+func New(db *gorm.DB, logger *zap.Logger, cfg Config) *Scheduler {
+	return &Scheduler{
+		repo:   repositories.NewNotificationRepository(db),
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	s.logger.Info("Scheduler started", zap.Duration("poll_interval", s.cfg.PollInterval))
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Scheduler stopped")
+			return
+		case <-ticker.C:
+			for {
+				n, err := s.releaseBatch(time.Now())
+				if err != nil {
+					s.logger.Error("scheduler batch failed", zap.Error(err))
+					break
+				}
+				if n > 0 {
+					s.logger.Info("released scheduled notifications", zap.Int("count", n))
+				}
+				if n < s.cfg.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (s *Scheduler) releaseBatch(now time.Time) (int, error) {
+	var released int
+	err := s.repo.Transaction(func(tx *repositories.NotificationRepository) error {
+		due, err := tx.LockDueScheduled(now, s.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		ids := make([]uuid.UUID, len(due))
+		for i, n := range due {
+			ids[i] = n.ID
+		}
+		released = len(ids)
+		return tx.ReleaseScheduled(ids, now)
+	})
+	return released, err
+}
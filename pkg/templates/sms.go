@@ -6,12 +6,14 @@ import (
 	html "html/template"
 	text "text/template"
 
+	"github.com/google/uuid"
 	"github.com/jsndz/signalbus/pkg/repositories"
 )
 
 
 func Render(
     data map[string]interface{},
+    tenantID uuid.UUID,
     channel, name, locale string,
     contentTypes []string,
     repo *repositories.TemplateRepository,
@@ -21,7 +23,7 @@ func Render(
 
 
     for _, ct := range contentTypes {
-        tmpl, err := repo.GetByLookup( channel, name, locale, ct)
+        tmpl, err := repo.GetByLookup(tenantID, channel, name, locale, ct)
         if err != nil {
             return nil, fmt.Errorf("failed to fetch template (%s): %w", ct, err)
         }
@@ -1,16 +1,122 @@
 package types
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxScheduleAhead is the furthest in the future a notification can be
+// scheduled.
+const MaxScheduleAhead = 365 * 24 * time.Hour
+
+// Schedule delays delivery. SendAt is an RFC 3339 timestamp and Delay a Go
+// duration such as "15m" or "36h"; at most one may be given.
+type Schedule struct {
+	SendAt *time.Time `json:"send_at,omitempty"`
+	Delay  string     `json:"delay,omitempty"`
+}
+
+// Resolve returns when the notification should be sent, or nil to send it
+// immediately. A SendAt in the past is treated as immediate.
+func (s Schedule) Resolve(now time.Time) (*time.Time, error) {
+	if s.SendAt != nil && s.Delay != "" {
+		return nil, errors.New("send_at and delay cannot both be set")
+	}
+	var at time.Time
+	switch {
+	case s.SendAt != nil:
+		at = *s.SendAt
+	case s.Delay != "":
+		d, err := time.ParseDuration(s.Delay)
+		if err != nil {
+			return nil, errors.New("delay must be a duration such as 15m or 2h")
+		}
+		if d < 0 {
+			return nil, errors.New("delay cannot be negative")
+		}
+		at = now.Add(d)
+	default:
+		return nil, nil
+	}
+	if !at.After(now) {
+		return nil, nil
+	}
+	if at.Sub(now) > MaxScheduleAhead {
+		return nil, errors.New("notifications can be scheduled at most one year ahead")
+	}
+	at = at.UTC()
+	return &at, nil
+}
 
 type NotifyRequest struct {
 	EventType      string                 `json:"event_type" binding:"required"`
 	UserRef			string					`json:"user_ref" binding:"required"`
-	UserData       map[string]interface{} `json:"data" binding:"required"`
+	// UserData carries recipients and sender fields. Recipients may be left
+	// out when the user has a contact on file.
+	UserData       map[string]interface{} `json:"data"`
 	TemplateData   map[string]interface{} `json:"template_data,omitempty"`
+	// Priority is high, normal or bulk. It defaults to high for urgent
+	// policies and normal otherwise.
+	Priority string `json:"priority,omitempty"`
+	// CollapseKey folds this notification with others for the same user and
+	// event type that carry the same key, within CollapseWindow (a Go
+	// duration) or the policy's window. CollapseMode is suppress or replace.
+	CollapseKey    string `json:"collapse_key,omitempty"`
+	CollapseWindow string `json:"collapse_window,omitempty"`
+	CollapseMode   string `json:"collapse_mode,omitempty"`
+	Schedule
+}
+
+// BatchNotifyItem is one entry of POST /api/notify/batch. The key plays the
+// role of the X-Idempotency-Key header for that item alone.
+type BatchNotifyItem struct {
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	NotifyRequest
+}
+
+
+// FallbackEvent is published to notification.fallback when a worker gives up
+// on a notification.
+type FallbackEvent struct {
+	TenantID       uuid.UUID `json:"tenant_id"`
+	NotificationID uuid.UUID `json:"notification_id"`
+	Channel        string    `json:"channel"`
+	Reason         string    `json:"reason"`
+	FailedAt       time.Time `json:"failed_at"`
 }
 
+// InboundSMSTopic carries every text recipients send us.
+const InboundSMSTopic = "notification.sms.inbound"
+
+// InboundSMS is published to InboundSMSTopic for every inbound text, so
+// other services can act on replies. Keyword is STOP, START or HELP when the
+// text was one, after the opt-out store has been updated.
+type InboundSMS struct {
+	ID                uuid.UUID `json:"id"`
+	Provider          string    `json:"provider"`
+	ProviderMessageID string    `json:"provider_message_id"`
+	From              string    `json:"from"`
+	To                string    `json:"to"`
+	Body              string    `json:"body"`
+	Keyword           string    `json:"keyword,omitempty"`
+	ReceivedAt        time.Time `json:"received_at"`
+}
+
+// SetRecipient sets the address a channel worker sends to: a list for email
+// and a single number for SMS.
+func SetRecipient(data map[string]interface{}, channel, addr string) {
+	if channel == "email" {
+		data["to"] = []interface{}{addr}
+		return
+	}
+	data["to"] = addr
+}
 
 type KafkaStreamData struct {
+	TenantID        uuid.UUID               `json:"tenant_id"`
 	GetTemplateData *GetTemplateData       	`json:"get_template_data"`
 	InTemplateData  map[string]interface{} 	`json:"in_template_data,omitempty"`
 	RecieverData    map[string]interface{} 	`json:"reciever_data,omitempty"`
@@ -20,6 +126,22 @@ type KafkaStreamData struct {
 
 	IdempotencyKey  string                 	`json:"idempotency_key"`
 	NotificationId  uuid.UUID				`json:"notification_id"`
+	// Provider overrides the worker's default provider when set.
+	Provider        string                  `json:"provider,omitempty"`
+}
+
+// DecodeDLQMessage reads the message a worker stored on its dlq delivery
+// attempt so it can be redriven. A payload without a recipient, such as the
+// rendered email older workers stored, cannot be republished and is rejected.
+func DecodeDLQMessage(b []byte) (KafkaStreamData, error) {
+	var msg KafkaStreamData
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return msg, err
+	}
+	if len(msg.RecieverData) == 0 {
+		return msg, errors.New("DLQ message has no recipient")
+	}
+	return msg, nil
 }
 
 type GetTemplateData struct {
@@ -36,7 +158,8 @@ type PublishRequest struct {
 	TextMessage     string 					`json:"text_message"`
 	HTMLMessage     string 					`json:"html_message"`
 	UserRef			string					`json:"user_ref" binding:"required"`
-
+	Priority 	string 					`json:"priority"`
+	Schedule
 }
 
 
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleResolve(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(2 * time.Hour)
+	past := now.Add(-time.Minute)
+
+	at, err := Schedule{Delay: "90m"}.Resolve(now)
+	if err != nil || at == nil || !at.Equal(now.Add(90*time.Minute)) {
+		t.Fatalf("delay: got %v, %v", at, err)
+	}
+	at, err = Schedule{SendAt: &future}.Resolve(now)
+	if err != nil || at == nil || !at.Equal(future) {
+		t.Fatalf("send_at: got %v, %v", at, err)
+	}
+	if at, err := (Schedule{SendAt: &past}).Resolve(now); err != nil || at != nil {
+		t.Fatalf("past send_at should send immediately, got %v, %v", at, err)
+	}
+	if at, err := (Schedule{}).Resolve(now); err != nil || at != nil {
+		t.Fatalf("empty schedule should send immediately, got %v, %v", at, err)
+	}
+}
+
+func TestScheduleResolveRejectsInvalid(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Hour)
+	cases := map[string]Schedule{
+		"both":      {SendAt: &future, Delay: "1h"},
+		"bad delay": {Delay: "tomorrow"},
+		"negative":  {Delay: "-5m"},
+		"too far":   {Delay: "9000h"},
+	}
+	for name, s := range cases {
+		if _, err := s.Resolve(now); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDecodeDLQMessageRejectsRenderedEmail(t *testing.T) {
+	// what the email worker stored before it kept the consumed message
+	rendered := []byte(`{"From":"shop@example.com","To":["ada@example.com"],"Subject":"Your order","HTML":"<p>hi</p>"}`)
+	if _, err := DecodeDLQMessage(rendered); err == nil {
+		t.Error("expected an error for a payload without a recipient")
+	}
+}
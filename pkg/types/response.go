@@ -9,3 +9,13 @@ type SendResponse struct{
     RawResponse    []byte    
     Timestamp      time.Time
 }
+
+// StatusAwaitingReceipt is the Status of a message the provider accepted and
+// will report on later through a webhook. Until the report arrives its
+// notification is sent rather than delivered.
+const StatusAwaitingReceipt = "sent"
+
+// AttemptAccepted is the status of the worker's own attempt for a message
+// awaiting a receipt. It is none of the statuses providers report, so a
+// provider's "sent" receipt is recorded as an attempt of its own.
+const AttemptAccepted = "accepted"
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKeyIsUniqueAndPrefixed(t *testing.T) {
+	a, prefixA, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a == b {
+		t.Errorf("expected two different keys, got %q twice", a)
+	}
+	if !strings.HasPrefix(a, prefixA) {
+		t.Errorf("expected key %q to start with prefix %q", a, prefixA)
+	}
+}
+
+func TestHashAPIKeyIsStable(t *testing.T) {
+	if HashAPIKey("sb_abc") != HashAPIKey("sb_abc") {
+		t.Error("expected the same key to hash to the same value")
+	}
+	if HashAPIKey("sb_abc") == HashAPIKey("sb_abd") {
+		t.Error("expected different keys to hash differently")
+	}
+}